@@ -0,0 +1,188 @@
+// Package checksum computes checksums of files with gotfp,
+// and writes and verifies manifests compatible with sha256sum and
+// the like of GNU coreutils, in their GNU and BSD (--tag) formats.
+package checksum
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+type Algorithm int8
+type Format int8
+
+const (
+	MD5 Algorithm = iota + 1
+	SHA1
+	SHA256
+	SHA512
+)
+
+const (
+	GNU Format = iota + 1 // e.g., "<digest>  <path>", by sha256sum.
+	BSD                   // e.g., "SHA256 (<path>) = <digest>", by sha256sum --tag.
+)
+
+var algorithmStrings = [...]string{
+	"Unknown",
+	"MD5",
+	"SHA1",
+	"SHA256",
+	"SHA512",
+}
+
+var formatStrings = [...]string{
+	"Unknown",
+	"GNU",
+	"BSD",
+}
+
+// Algorithms are all known algorithms.
+var Algorithms = []Algorithm{MD5, SHA1, SHA256, SHA512}
+
+func ParseAlgorithm(s string) Algorithm {
+	for i := range algorithmStrings {
+		if strings.EqualFold(s, algorithmStrings[i]) {
+			return Algorithm(i)
+		}
+	}
+	return 0 // Stands for "Unknown".
+}
+
+func (alg Algorithm) String() string {
+	if !alg.IsKnown() {
+		return algorithmStrings[0]
+	}
+	return algorithmStrings[alg]
+}
+
+func (alg Algorithm) MarshalText() ([]byte, error) {
+	return []byte(alg.String()), nil
+}
+
+func (alg *Algorithm) UnmarshalText(text []byte) error {
+	*alg = ParseAlgorithm(string(text))
+	return nil
+}
+
+// IsKnown reports whether alg is a known algorithm.
+func (alg Algorithm) IsKnown() bool {
+	return alg >= MD5 && alg <= SHA512
+}
+
+// New returns a new hash.Hash computing the checksum.
+// It panics if alg is unknown.
+func (alg Algorithm) New() hash.Hash {
+	switch alg {
+	case MD5:
+		return md5.New()
+	case SHA1:
+		return sha1.New()
+	case SHA256:
+		return sha256.New()
+	case SHA512:
+		return sha512.New()
+	}
+	panic("checksum: algorithm " + alg.String() + " is unknown")
+}
+
+// Size returns the length of the checksum in bytes,
+// or 0 if alg is unknown.
+func (alg Algorithm) Size() int {
+	switch alg {
+	case MD5:
+		return md5.Size
+	case SHA1:
+		return sha1.Size
+	case SHA256:
+		return sha256.Size
+	case SHA512:
+		return sha512.Size
+	}
+	return 0
+}
+
+// algorithmOfHexLen returns the algorithm whose checksum is n hex digits,
+// or 0 if there is none.
+func algorithmOfHexLen(n int) Algorithm {
+	for _, alg := range Algorithms {
+		if alg.Size()*2 == n {
+			return alg
+		}
+	}
+	return 0
+}
+
+func ParseFormat(s string) Format {
+	for i := range formatStrings {
+		if strings.EqualFold(s, formatStrings[i]) {
+			return Format(i)
+		}
+	}
+	return 0 // Stands for "Unknown".
+}
+
+func (f Format) String() string {
+	if f < GNU || f > BSD {
+		return formatStrings[0]
+	}
+	return formatStrings[f]
+}
+
+func (f Format) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+func (f *Format) UnmarshalText(text []byte) error {
+	*f = ParseFormat(string(text))
+	return nil
+}
+
+// Sum is the checksums of a file.
+type Sum struct {
+	// Path is the slash-separated path of the file relative to the root.
+	Path string
+	Size int64
+	// Digests are the hex-encoded checksums by algorithm.
+	Digests map[Algorithm]string
+}
+
+// HashFile computes the checksums of the file name by algs
+// in a single pass, and returns them hex-encoded, with the bytes read.
+// It panics if any algorithm is unknown.
+func HashFile(name string, algs ...Algorithm) (
+	digests map[Algorithm]string, n int64, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close() // Ignore error.
+	return HashReader(f, algs...)
+}
+
+// HashReader is like HashFile, but reads from r.
+func HashReader(r io.Reader, algs ...Algorithm) (
+	digests map[Algorithm]string, n int64, err error) {
+	hs := make([]hash.Hash, len(algs))
+	ws := make([]io.Writer, len(algs))
+	for i, alg := range algs {
+		hs[i] = alg.New()
+		ws[i] = hs[i]
+	}
+	n, err = io.Copy(io.MultiWriter(ws...), r)
+	if err != nil {
+		return nil, n, err
+	}
+	digests = make(map[Algorithm]string, len(algs))
+	for i, alg := range algs {
+		digests[alg] = hex.EncodeToString(hs[i].Sum(nil))
+	}
+	return digests, n, nil
+}
@@ -0,0 +1,57 @@
+package checksum
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testAbcDigests = map[Algorithm]string{
+	MD5:    "900150983cd24fb0d6963f7d28e17f72",
+	SHA1:   "a9993e364706816aba3e25717850c26c9cd0d89d",
+	SHA256: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+	SHA512: "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a" +
+		"2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f",
+}
+
+func TestHashFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "abc")
+	if err := os.WriteFile(name, []byte("abc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	digests, n, err := HashFile(name, Algorithms...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("got %d bytes; want 3", n)
+	}
+	for _, alg := range Algorithms {
+		if digests[alg] != testAbcDigests[alg] {
+			t.Errorf("%s: got %s; want %s", alg, digests[alg], testAbcDigests[alg])
+		}
+	}
+	if _, _, err = HashFile(name + ".none"); !os.IsNotExist(err) {
+		t.Errorf("got %v; want not exist", err)
+	}
+}
+
+func TestAlgorithm(t *testing.T) {
+	for _, alg := range Algorithms {
+		if got := ParseAlgorithm(alg.String()); got != alg {
+			t.Errorf("parse %s: got %v", alg, got)
+		}
+		if alg.New().Size() != alg.Size() {
+			t.Errorf("%s: got size %d; want %d", alg, alg.New().Size(), alg.Size())
+		}
+		if got := algorithmOfHexLen(alg.Size() * 2); got != alg {
+			t.Errorf("hex length of %s: got %v", alg, got)
+		}
+	}
+	if got := ParseAlgorithm("sha256"); got != SHA256 {
+		t.Errorf("got %v; want SHA256", got)
+	}
+	if got := ParseAlgorithm("CRC32"); got.IsKnown() || got.String() != "Unknown" {
+		t.Errorf("got %v; want Unknown", got)
+	}
+}
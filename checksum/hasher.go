@@ -0,0 +1,263 @@
+package checksum
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/donyori/gotfp"
+)
+
+type config struct {
+	traverserOpts []gotfp.Option
+	algs          []Algorithm
+	workerNumber  int
+	queueSize     int
+}
+
+// Hasher computes checksums of the regular files in a tree.
+//
+// Files are found by a Traverser, whose workers only queue them,
+// and hashed by the workers of Hasher, so that the traversal is not
+// blocked on reading files unless the queue is full.
+// It is configured by options when created and can be reused,
+// also concurrently.
+type Hasher struct {
+	cfg config
+}
+
+// Option configures a Hasher.
+type Option func(cfg *config)
+
+// NewHasher creates a Hasher with given options.
+//
+// By default, it computes SHA256 checksums with GOMAXPROCS workers,
+// and uses the default options of gotfp.NewTraverser.
+func NewHasher(opts ...Option) *Hasher {
+	h := &Hasher{cfg: config{algs: []Algorithm{SHA256}}}
+	for _, opt := range opts {
+		opt(&h.cfg)
+	}
+	return h
+}
+
+// WithAlgorithms sets the algorithms to compute, all in a single pass.
+//
+// It panics if algs is empty or any algorithm is unknown.
+func WithAlgorithms(algs ...Algorithm) Option {
+	if len(algs) == 0 {
+		panic(errors.New("checksum: no algorithm"))
+	}
+	for _, alg := range algs {
+		if !alg.IsKnown() {
+			panic(errors.New("checksum: algorithm " + alg.String() + " is unknown"))
+		}
+	}
+	algs = append(algs[:0:0], algs...)
+	return func(cfg *config) {
+		cfg.algs = algs
+	}
+}
+
+// WithTraverserOptions adds options of the Traverser to find files,
+// e.g., gotfp.WithExclude and gotfp.WithFollowSymlinks.
+func WithTraverserOptions(opts ...gotfp.Option) Option {
+	return func(cfg *config) {
+		cfg.traverserOpts = append(cfg.traverserOpts, opts...)
+	}
+}
+
+// WithWorkerNumber sets the number of workers to hash files.
+// Non-positive n stands for GOMAXPROCS.
+func WithWorkerNumber(n int) Option {
+	return func(cfg *config) {
+		cfg.workerNumber = n
+	}
+}
+
+// WithQueueSize sets the number of files found but not hashed yet,
+// beyond which the traversal waits for the workers of Hasher.
+// Non-positive n stands for 64 times the number of workers.
+func WithQueueSize(n int) Option {
+	return func(cfg *config) {
+		cfg.queueSize = n
+	}
+}
+
+// Sum computes the checksums of the regular files in root.
+//
+// The Sums are sorted by path, relative to root. If root is a regular file,
+// the path is its base name.
+//
+// Files are read through the Traverser (see gotfp.Traverser.Open),
+// so from the file system set by gotfp.WithFS, if any.
+//
+// The Sums are returned even if an error occurs. Files that cannot be read
+// are left out. The error joins the one returned by gotfp.Traverser.Files
+// and those of reading files, and includes ctx.Err() if ctx is done.
+func (h *Hasher) Sum(ctx context.Context, root string) ([]*Sum, error) {
+	return h.sum(ctx, root, h.cfg.algs)
+}
+
+// Ensure algs is not empty.
+func (h *Hasher) sum(ctx context.Context, root string,
+	algs []Algorithm) ([]*Sum, error) {
+	n := h.cfg.workerNumber
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	queueSize := h.cfg.queueSize
+	if queueSize <= 0 {
+		queueSize = n * 64
+	}
+	t := gotfp.NewTraverser(h.cfg.traverserOpts...)
+	type job struct {
+		Path string // Path to open.
+		Rel  string // Path in Sum.
+	}
+	jobs := make(chan job, queueSize)
+	var mu sync.Mutex
+	var sums []*Sum
+	var errs []error
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if ctx.Err() != nil {
+					continue // Drain the queue.
+				}
+				digests, size, err := hashFile(t, j.Path, algs)
+				mu.Lock()
+				if err != nil {
+					if !errors.As(err, new(*fs.PathError)) {
+						err = &fs.PathError{Op: "read", Path: j.Path, Err: err}
+					}
+					errs = append(errs, err)
+				} else {
+					sums = append(sums, &Sum{Path: j.Rel, Size: size, Digests: digests})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	err := t.Files(ctx, func(info gotfp.FileInfo, depth int) gotfp.Action {
+		if info.Cat != gotfp.RegularFile {
+			return gotfp.ActionContinue
+		}
+		select {
+		case jobs <- job{Path: info.Path, Rel: relPath(info.Path, depth)}:
+			return gotfp.ActionContinue
+		case <-ctx.Done():
+			return gotfp.ActionExit
+		}
+	}, root)
+	close(jobs)
+	wg.Wait()
+	if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+		// The traversal is stopped by ActionExit, returned for the file
+		// that cannot be queued, rather than by ctx.
+		err = errors.Join(err, ctxErr)
+	}
+	sort.Slice(sums, func(i, j int) bool {
+		return sums[i].Path < sums[j].Path
+	})
+	return sums, errors.Join(append([]error{err}, errs...)...)
+}
+
+// hashFile is like HashFile, but opens the file by t.
+func hashFile(t *gotfp.Traverser, name string, algs []Algorithm) (
+	digests map[Algorithm]string, n int64, err error) {
+	f, err := t.Open(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close() // Ignore error.
+	return HashReader(f, algs...)
+}
+
+// relPath returns the last depth elements of path, slash-separated,
+// i.e., the path relative to the root at depth 0, or the base name
+// if depth is 0.
+func relPath(path string, depth int) string {
+	path = filepath.ToSlash(path)
+	i := len(path)
+	if depth == 0 {
+		depth = 1
+	}
+	for ; depth > 0 && i >= 0; depth-- {
+		i = strings.LastIndexByte(path[:i], '/')
+	}
+	return path[i+1:]
+}
+
+// Report is the result of Hasher.Verify.
+// Paths are as in the manifest, sorted.
+type Report struct {
+	NumOK      int      // Number of files matching all their entries.
+	Mismatched []string // Files with a checksum different from an entry.
+	Missing    []string // Files in the manifest but not in the tree.
+	Extra      []string // Files in the tree but not in the manifest.
+}
+
+// OK reports whether the tree matches the manifest.
+func (r *Report) OK() bool {
+	return len(r.Mismatched) == 0 && len(r.Missing) == 0 && len(r.Extra) == 0
+}
+
+// Verify computes the checksums of the regular files in root,
+// by the algorithms of entries, and compares them with entries,
+// whose paths are relative to root, as written by WriteManifest.
+//
+// The report is returned even if an error occurs.
+// Files that cannot be read are reported as missing.
+// The error is as that returned by Sum.
+func (h *Hasher) Verify(ctx context.Context, root string,
+	entries []*Entry) (*Report, error) {
+	byPath := make(map[string][]*Entry, len(entries))
+	var algs []Algorithm
+	isUsed := make(map[Algorithm]bool)
+	for _, entry := range entries {
+		byPath[entry.Path] = append(byPath[entry.Path], entry)
+		if !isUsed[entry.Alg] {
+			isUsed[entry.Alg] = true
+			algs = append(algs, entry.Alg)
+		}
+	}
+	if len(algs) == 0 {
+		algs = h.cfg.algs
+	}
+	sums, err := h.sum(ctx, root, algs)
+	report := new(Report)
+	for _, sum := range sums {
+		es, ok := byPath[sum.Path]
+		if !ok {
+			report.Extra = append(report.Extra, sum.Path)
+			continue
+		}
+		delete(byPath, sum.Path)
+		isOK := true
+		for _, e := range es {
+			if sum.Digests[e.Alg] != e.Digest {
+				isOK = false
+				break
+			}
+		}
+		if isOK {
+			report.NumOK++
+		} else {
+			report.Mismatched = append(report.Mismatched, sum.Path)
+		}
+	}
+	for path := range byPath {
+		report.Missing = append(report.Missing, path)
+	}
+	sort.Strings(report.Missing)
+	return report, err
+}
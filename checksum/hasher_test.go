@@ -0,0 +1,169 @@
+package checksum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/donyori/gotfp"
+	"github.com/donyori/gotfp/internal/testfs"
+)
+
+func TestHasher_Sum(t *testing.T) {
+	root := testfs.MakeTree(t, map[string]string{
+		"abc": "abc", "d/e/abc": "abc", "d/f": "", "skip/abc": "abc",
+	})
+	h := NewHasher(WithAlgorithms(SHA256, MD5), WithWorkerNumber(3),
+		WithQueueSize(1), WithTraverserOptions(gotfp.WithExclude("skip/")))
+	sums, err := h.Sum(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, sum := range sums {
+		paths = append(paths, sum.Path)
+		if sum.Path == "d/f" {
+			continue
+		}
+		if sum.Size != 3 || sum.Digests[SHA256] != testAbcDigests[SHA256] ||
+			sum.Digests[MD5] != testAbcDigests[MD5] || len(sum.Digests) != 2 {
+			t.Errorf("%s: got %+v", sum.Path, *sum)
+		}
+	}
+	if want := []string{"abc", "d/e/abc", "d/f"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("got %v; want %v", paths, want)
+	}
+
+	sums, err = h.Sum(context.Background(), filepath.Join(root, "d", "e", "abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sums) != 1 || sums[0].Path != "abc" {
+		t.Errorf("file root: got %v", sums)
+	}
+
+	t.Chdir(root)
+	sums, err = h.Sum(context.Background(), "d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths = paths[:0]
+	for _, sum := range sums {
+		paths = append(paths, sum.Path)
+	}
+	if want := []string{"e/abc", "f"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("relative root: got %v; want %v", paths, want)
+	}
+}
+
+func TestHasher_Sum_FS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"d/abc":   {Data: []byte("abc")},
+		"d/e/abc": {Data: []byte("abc")},
+	}
+	for _, root := range []string{".", "d"} {
+		sums, err := NewHasher(WithTraverserOptions(gotfp.WithFS(fsys),
+			gotfp.WithMinDepth(1))).Sum(context.Background(), root)
+		if err != nil {
+			t.Error(err)
+		}
+		var paths []string
+		for _, sum := range sums {
+			paths = append(paths, sum.Path)
+			if sum.Digests[SHA256] != testAbcDigests[SHA256] {
+				t.Errorf("%s: got %+v", sum.Path, *sum)
+			}
+		}
+		want := []string{"abc", "e/abc"}
+		if root == "." {
+			want = []string{"d/abc", "d/e/abc"}
+		}
+		if !reflect.DeepEqual(paths, want) {
+			t.Errorf("root %q: got %v; want %v", root, paths, want)
+		}
+	}
+}
+
+// testCancelFS calls Cancel when the file Name is opened.
+type testCancelFS struct {
+	fs.FS
+	Name   string
+	Cancel context.CancelFunc
+}
+
+func (f testCancelFS) Open(name string) (fs.File, error) {
+	if name == f.Name {
+		f.Cancel()
+	}
+	return f.FS.Open(name)
+}
+
+func TestHasher_Sum_Cancel(t *testing.T) {
+	fsys := make(fstest.MapFS)
+	for i := 0; i < 100; i++ {
+		fsys[fmt.Sprintf("%02d", i)] = &fstest.MapFile{Data: []byte("abc")}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := NewHasher(WithWorkerNumber(1), WithQueueSize(1),
+		WithTraverserOptions(gotfp.WithFS(testCancelFS{
+			FS: fsys, Name: "00", Cancel: cancel,
+		})))
+	sums, err := h.Sum(ctx, ".")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v; want %v", err, context.Canceled)
+	}
+	if len(sums) == len(fsys) {
+		t.Error("got all sums")
+	}
+	report, err := h.Verify(ctx, ".", nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("verify: got error %v, report %+v; want %v",
+			err, *report, context.Canceled)
+	}
+}
+
+func TestHasher_Verify(t *testing.T) {
+	root := testfs.MakeTree(t, map[string]string{
+		"ok": "abc", "d/ok": "abc", "bad": "abd", "bad2": "abc", "extra": "abc",
+	})
+	entries := []*Entry{
+		{Path: "ok", Alg: SHA256, Digest: testAbcDigests[SHA256]},
+		{Path: "ok", Alg: MD5, Digest: testAbcDigests[MD5]},
+		{Path: "d/ok", Alg: SHA1, Digest: testAbcDigests[SHA1]},
+		{Path: "bad", Alg: SHA256, Digest: testAbcDigests[SHA256]},
+		{Path: "bad2", Alg: SHA256, Digest: testAbcDigests[SHA256]},
+		{Path: "bad2", Alg: MD5, Digest: testAbcDigests[SHA1][:32]},
+		{Path: "gone", Alg: SHA256, Digest: testAbcDigests[SHA256]},
+	}
+	report, err := NewHasher().Verify(context.Background(), root, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Report{
+		NumOK:      2,
+		Mismatched: []string{"bad", "bad2"},
+		Missing:    []string{"gone"},
+		Extra:      []string{"extra"},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("got %+v; want %+v", *report, *want)
+	}
+	if report.OK() {
+		t.Error("got OK")
+	}
+
+	report, err = NewHasher().Verify(context.Background(), filepath.Join(root, "d"),
+		[]*Entry{{Path: "ok", Alg: SHA1, Digest: testAbcDigests[SHA1]}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || report.NumOK != 1 {
+		t.Errorf("got %+v; want OK", *report)
+	}
+}
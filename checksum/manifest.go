@@ -0,0 +1,179 @@
+package checksum
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Entry is a line of a manifest.
+type Entry struct {
+	Path   string // Slash-separated path, as in the manifest.
+	Alg    Algorithm
+	Digest string // Hex-encoded checksum, in lower case.
+}
+
+// SyntaxError is reported when a line of a manifest is malformed.
+type SyntaxError struct {
+	Line int    // Line number, from 1.
+	Text string // The malformed line.
+	Msg  string
+}
+
+func (se *SyntaxError) Error() string {
+	return fmt.Sprintf("checksum: line %d: %s: %q", se.Line, se.Msg, se.Text)
+}
+
+// WriteManifest writes the checksums of sums by alg to w, a line per file,
+// in format. The lines are in the order of sums.
+// As GNU coreutils, a path with a backslash or a newline is escaped,
+// and its line starts with a backslash.
+//
+// It panics if alg or format is unknown,
+// and returns an error if a Sum has no checksum by alg.
+func WriteManifest(w io.Writer, sums []*Sum, alg Algorithm,
+	format Format) error {
+	if !alg.IsKnown() {
+		panic("checksum: algorithm " + alg.String() + " is unknown")
+	}
+	if format != GNU && format != BSD {
+		panic("checksum: format " + format.String() + " is unknown")
+	}
+	bw := bufio.NewWriter(w)
+	for _, sum := range sums {
+		digest, ok := sum.Digests[alg]
+		if !ok {
+			return fmt.Errorf("checksum: no %s checksum of %q", alg, sum.Path)
+		}
+		path, isEscaped := escape(sum.Path)
+		if isEscaped {
+			bw.WriteByte('\\')
+		}
+		if format == GNU {
+			fmt.Fprintf(bw, "%s  %s\n", digest, path)
+		} else {
+			fmt.Fprintf(bw, "%s (%s) = %s\n", alg, path, digest)
+		}
+	}
+	return bw.Flush()
+}
+
+// ParseManifest parses a manifest from r.
+// Lines can be in either format, and blank lines are ignored.
+// The algorithm of a line in the GNU format is told by the length
+// of its checksum.
+// It returns a *SyntaxError for a malformed line.
+func ParseManifest(r io.Reader) ([]*Entry, error) {
+	var entries []*Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		entry, msg := parseLine(line)
+		if msg != "" {
+			return entries, &SyntaxError{Line: lineNo, Text: line, Msg: msg}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// parseLine parses a line, or returns the message of the syntax error.
+func parseLine(line string) (entry *Entry, msg string) {
+	isEscaped := strings.HasPrefix(line, `\`)
+	if isEscaped {
+		line = line[1:]
+	}
+	entry = new(Entry)
+	var path string
+	if algName, rest, ok := strings.Cut(line, " ("); ok &&
+		!strings.Contains(algName, " ") && strings.Contains(rest, ") = ") {
+		i := strings.LastIndex(rest, ") = ")
+		path, entry.Digest = rest[:i], rest[i+len(") = "):]
+		entry.Alg = ParseAlgorithm(algName)
+		if !entry.Alg.IsKnown() {
+			return nil, "unknown algorithm"
+		}
+		if len(entry.Digest) != entry.Alg.Size()*2 {
+			return nil, "bad checksum length"
+		}
+	} else {
+		var ok bool
+		entry.Digest, path, ok = strings.Cut(line, " ")
+		if !ok || path == "" || (path[0] != ' ' && path[0] != '*') {
+			return nil, "bad format"
+		}
+		path = path[1:] // Drop the mode, text (' ') or binary ('*').
+		entry.Alg = algorithmOfHexLen(len(entry.Digest))
+		if entry.Alg == 0 {
+			return nil, "bad checksum length"
+		}
+	}
+	if !isHex(entry.Digest) {
+		return nil, "bad checksum"
+	}
+	entry.Digest = strings.ToLower(entry.Digest)
+	if isEscaped {
+		var err error
+		if path, err = unescape(path); err != nil {
+			return nil, err.Error()
+		}
+	}
+	if path == "" {
+		return nil, "empty path"
+	}
+	entry.Path = path
+	return entry, ""
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+var pathEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+// escape escapes path as GNU coreutils,
+// and reports whether path needs escaping.
+func escape(path string) (escaped string, isEscaped bool) {
+	if !strings.ContainsAny(path, "\\\n\r") {
+		return path, false
+	}
+	return pathEscaper.Replace(path), true
+}
+
+// unescape reverts escape.
+func unescape(s string) (string, error) {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i++; i == len(s) {
+			return "", errors.New("bad escape")
+		}
+		switch s[i] {
+		case '\\':
+			b.WriteByte('\\')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			return "", errors.New("bad escape")
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,94 @@
+package checksum
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteManifest(t *testing.T) {
+	sums := []*Sum{
+		{Path: "abc", Size: 3, Digests: testAbcDigests},
+		{Path: `a\b`, Size: 3, Digests: map[Algorithm]string{SHA256: "c62016d0f8ee333350283fd879b50b692932e932794e5d686f7d37d67484e199"}},
+		{Path: "dir/x y\nz", Size: 3, Digests: map[Algorithm]string{SHA256: testAbcDigests[SHA256]}},
+	}
+	// As written by sha256sum and sha256sum --tag of GNU coreutils.
+	testCases := []struct {
+		format Format
+		want   string
+	}{
+		{GNU, `ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad  abc
+\c62016d0f8ee333350283fd879b50b692932e932794e5d686f7d37d67484e199  a\\b
+\ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad  dir/x y\nz
+`},
+		{BSD, `SHA256 (abc) = ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad
+\SHA256 (a\\b) = c62016d0f8ee333350283fd879b50b692932e932794e5d686f7d37d67484e199
+\SHA256 (dir/x y\nz) = ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad
+`},
+	}
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		if err := WriteManifest(&buf, sums, SHA256, tc.format); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tc.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tc.format, buf.String(), tc.want)
+		}
+		entries, err := ParseManifest(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(sums) {
+			t.Fatalf("%s: got %d entries; want %d", tc.format, len(entries), len(sums))
+		}
+		for i, e := range entries {
+			want := &Entry{Path: sums[i].Path, Alg: SHA256, Digest: sums[i].Digests[SHA256]}
+			if !reflect.DeepEqual(e, want) {
+				t.Errorf("%s: got %+v; want %+v", tc.format, *e, *want)
+			}
+		}
+	}
+	if err := WriteManifest(new(bytes.Buffer), sums, MD5, GNU); err == nil {
+		t.Error("no error for missing checksum")
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	manifest := "900150983CD24FB0D6963F7D28E17F72 *bin/abc\r\n" +
+		"\n" +
+		"SHA1 (a (1)) = a9993e364706816aba3e25717850c26c9cd0d89d\n" +
+		"a9993e364706816aba3e25717850c26c9cd0d89d  b (2) = c\n"
+	entries, err := ParseManifest(strings.NewReader(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Entry{
+		{Path: "bin/abc", Alg: MD5, Digest: testAbcDigests[MD5]},
+		{Path: "a (1)", Alg: SHA1, Digest: testAbcDigests[SHA1]},
+		{Path: "b (2) = c", Alg: SHA1, Digest: testAbcDigests[SHA1]},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got %v; want %v", entries, want)
+	}
+
+	for _, line := range []string{
+		"abc  x",
+		testAbcDigests[SHA1] + "x",
+		"CRC32 (x) = 352441c2",
+		"SHA1 (x) = " + testAbcDigests[MD5],
+		"SHA1 (x) = " + strings.Repeat("g", 40),
+		`\` + testAbcDigests[MD5] + `  a\b`,
+		testAbcDigests[MD5] + "  ",
+	} {
+		entries, err := ParseManifest(strings.NewReader(testAbcDigests[MD5] + "  ok\n" + line))
+		var se *SyntaxError
+		if !errors.As(err, &se) || se.Line != 2 {
+			t.Errorf("%q: got %v; want syntax error on line 2", line, err)
+		}
+		if len(entries) != 1 {
+			t.Errorf("%q: got %d entries; want 1", line, len(entries))
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package gotfp
+
+import (
+	"io"
+	"io/fs"
+	"sync"
+)
+
+// tDirReader reads the entries of a directory chunk by chunk,
+// like fs.ReadDirFile.
+type tDirReader interface {
+	ReadDir(n int) ([]fs.DirEntry, error)
+	Close() error
+}
+
+// tDirReaderSet records the directories being read chunk by chunk,
+// so that they can be closed if the traversal exits early.
+// The zero value is ready to use.
+type tDirReaderSet struct {
+	mu sync.Mutex
+	m  map[tDirReader]bool
+}
+
+func (s *tDirReaderSet) add(r tDirReader) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = make(map[tDirReader]bool)
+	}
+	s.m[r] = true
+}
+
+// close closes r and removes it from s.
+func (s *tDirReaderSet) close(r tDirReader) {
+	s.mu.Lock()
+	delete(s.m, r)
+	s.mu.Unlock()
+	r.Close() // Ignore error.
+}
+
+// closeAll closes all readers remaining in s.
+func (s *tDirReaderSet) closeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for r := range s.m {
+		r.Close() // Ignore error.
+	}
+	s.m = nil
+}
+
+// readChunk sets the entries and Chldn of the directory of task to its
+// next chunk, and reads the chunk after it ahead, so that task.DirReader
+// is nil if and only if the chunk is the last.
+// It returns an error if the first chunk cannot be read.
+// Errors occurring when reading ahead are appended to errBuf,
+// and then the chunk becomes the last.
+// Ensure tr.Cfg.chunkSize > 0 && task.FileInfo.Cat == Directory.
+func (tr *tTraversal) readChunk(task *tTask, errBuf *[]error) error {
+	n := tr.Cfg.chunkSize
+	if task.Chunk == 0 {
+		r, err := tr.FS.OpenDir(task.FileInfo.Path)
+		if err != nil {
+			return err
+		}
+		tr.OpenDirs.add(r)
+		task.DirReader = r
+		task.NextEntries, err = readDirChunk(r, n)
+		if err != nil {
+			tr.OpenDirs.close(r)
+			task.DirReader, task.NextEntries = nil, nil
+			return err
+		}
+	}
+	entries := task.NextEntries
+	task.NextEntries = nil
+	if task.DirReader != nil && len(entries) > 0 {
+		var err error
+		task.NextEntries, err = readDirChunk(task.DirReader, n)
+		if err != nil {
+			*errBuf = append(*errBuf, err)
+		}
+	}
+	if len(task.NextEntries) == 0 && task.DirReader != nil {
+		tr.OpenDirs.close(task.DirReader)
+		task.DirReader = nil
+	}
+	if tr.Cfg.sortOrder != SortNone && len(entries) > 0 {
+		sortDirEntries(entries)
+	}
+	var childrenNames []string
+	if len(entries) > 0 {
+		childrenNames = make([]string, len(entries))
+		for i := range entries {
+			childrenNames[i] = entries[i].Name()
+		}
+	}
+	task.FileInfo.Chldn, task.FileInfo.entries = childrenNames, entries
+	return nil
+}
+
+// nextChunkTask returns the task to read the next chunk of the directory,
+// or nil if task is for the last chunk.
+func nextChunkTask(task *tTask) *tTask {
+	if task.DirReader == nil {
+		return nil
+	}
+	fileInfo := task.FileInfo
+	fileInfo.Chldn, fileInfo.entries = nil, nil
+	return &tTask{
+		FileInfo:    fileInfo,
+		Root:        task.Root,
+		Ignore:      task.Ignore,
+		ExInfo:      task.ExInfo,
+		Chunk:       task.Chunk + 1,
+		DirReader:   task.DirReader,
+		NextEntries: task.NextEntries,
+	}
+}
+
+// readDirChunk reads at most n entries from r.
+// It returns no error at the end of the directory.
+func readDirChunk(r tDirReader, n int) ([]fs.DirEntry, error) {
+	entries, err := r.ReadDir(n)
+	if err == io.EOF {
+		err = nil
+	}
+	return entries, err
+}
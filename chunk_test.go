@@ -0,0 +1,141 @@
+package gotfp
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+)
+
+// testOpenFileCounter counts the files opened but not closed yet.
+type testOpenFileCounter struct {
+	fstest.MapFS
+	numOpen int64
+}
+
+type testCountedFile struct {
+	fs.ReadDirFile
+	c *testOpenFileCounter
+}
+
+func (f *testCountedFile) Close() error {
+	atomic.AddInt64(&f.c.numOpen, -1)
+	return f.ReadDirFile.Close()
+}
+
+func (c *testOpenFileCounter) Open(name string) (fs.File, error) {
+	f, err := c.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	rdf, ok := f.(fs.ReadDirFile)
+	if !ok {
+		return f, nil
+	}
+	atomic.AddInt64(&c.numOpen, 1)
+	return &testCountedFile{ReadDirFile: rdf, c: c}, nil
+}
+
+// testMakeWideFS returns a file system with n regular files
+// and a sub-directory "sub" in directory "wide".
+func testMakeWideFS(n int) fstest.MapFS {
+	fsys := fstest.MapFS{"wide/sub/x.txt": {}}
+	for i := 0; i < n; i++ {
+		fsys[fmt.Sprintf("wide/f%03d.txt", i)] = &fstest.MapFile{}
+	}
+	return fsys
+}
+
+func TestTraverser_Files_Chunk(t *testing.T) {
+	fsys := testMakeWideFS(25)
+	var mu sync.Mutex
+	var got []string
+	err := NewTraverser(WithFS(fsys), WithChunkSize(4)).Files(
+		context.Background(), func(info FileInfo, depth int) Action {
+			mu.Lock()
+			got = append(got, info.Path)
+			mu.Unlock()
+			return ActionContinue
+		}, "wide")
+	if err != nil {
+		t.Error(err)
+	}
+	// 25 files, "sub", "sub/x.txt", and the root.
+	if len(got) != 28 {
+		t.Errorf("got %d files; want 28", len(got))
+	}
+	sort.Strings(got)
+	for i := 1; i < len(got); i++ {
+		if got[i] == got[i-1] {
+			t.Errorf("%q visited more than once", got[i])
+		}
+	}
+}
+
+func TestTraverser_Batches_Chunk(t *testing.T) {
+	fsys := testMakeWideFS(25)
+	var mu sync.Mutex
+	var idxs []int
+	var numLast, numEntries int
+	err := NewTraverser(WithFS(fsys), WithChunkSize(4)).Batches(
+		context.Background(), func(batch Batch, depth int) (Action, map[string]bool) {
+			if batch.Parent.Path != "wide" {
+				return ActionContinue, nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(batch.Parent.Chldn) > 4 {
+				t.Errorf("chunk %d: %d children; want at most 4",
+					batch.ChunkIdx, len(batch.Parent.Chldn))
+			}
+			if depth != 0 {
+				t.Errorf("chunk %d: depth %d; want 0", batch.ChunkIdx, depth)
+			}
+			idxs = append(idxs, batch.ChunkIdx)
+			numEntries += len(batch.RegFiles) + len(batch.Dirs)
+			if batch.IsLastChunk {
+				numLast++
+			}
+			return ActionContinue, nil
+		}, "wide")
+	if err != nil {
+		t.Error(err)
+	}
+	sort.Ints(idxs)
+	for i := range idxs {
+		if idxs[i] != i {
+			t.Errorf("got chunks %v; want 0 to 6", idxs)
+			break
+		}
+	}
+	if len(idxs) != 7 {
+		t.Errorf("got %d chunks; want 7", len(idxs))
+	}
+	if numLast != 1 {
+		t.Errorf("got %d last chunks; want 1", numLast)
+	}
+	if numEntries != 26 {
+		t.Errorf("got %d entries; want 26", numEntries)
+	}
+}
+
+func TestTraverser_Chunk_ExitClosesDirs(t *testing.T) {
+	fsys := &testOpenFileCounter{MapFS: testMakeWideFS(100)}
+	err := NewTraverser(WithFS(fsys), WithChunkSize(2), WithWorkerNumber(1)).Files(
+		context.Background(), func(info FileInfo, depth int) Action {
+			if info.Path == "wide/f010.txt" {
+				return ActionExit
+			}
+			return ActionContinue
+		}, "wide")
+	if err != nil {
+		t.Error(err)
+	}
+	if n := atomic.LoadInt64(&fsys.numOpen); n != 0 {
+		t.Errorf("%d directories left open", n)
+	}
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/donyori/gotfp"
+	"github.com/donyori/gotfp/checksum"
+	"github.com/donyori/gotfp/dedup"
+	"github.com/donyori/gotfp/findexpr"
+)
+
+// tFileJSON is a file in the JSON output.
+type tFileJSON struct {
+	Path  string `json:"path"`
+	Type  string `json:"type"`
+	Size  int64  `json:"size"`
+	Depth int    `json:"depth"`
+	Err   string `json:"error,omitempty"`
+}
+
+func newFileJSON(path string, info gotfp.FileInfo, depth int) *tFileJSON {
+	f := &tFileJSON{Path: path, Type: typeName(info.Cat), Depth: depth}
+	if info.Err != nil {
+		f.Err = info.Err.Error()
+	} else if fi, err := info.LoadInfo(); err != nil {
+		f.Err = err.Error()
+	} else if fi != nil {
+		f.Size = fi.Size()
+	}
+	return f
+}
+
+func findCommand() *tCommand {
+	return &tCommand{
+		Name:    "find",
+		Args:    "[root ...] [expression]",
+		Summary: "Print files matching a find(1)-style expression",
+		Run: func(ctx context.Context, c *tCommon, args []string) error {
+			roots, exprArgs := splitRoots(args)
+			e, err := findexpr.Parse(exprArgs...)
+			if err != nil {
+				return usageErrorf("%v", err)
+			}
+			paths := newPaths(roots)
+			t := gotfp.NewTraverser(c.options()...)
+			e.DisplayPath, e.Traverser = paths.display, t
+			err = t.Files(ctx,
+				e.Handler(func(info gotfp.FileInfo, depth int) gotfp.Action {
+					path := paths.display(info.Path)
+					var err error
+					if c.JSON {
+						err = c.printJSON(newFileJSON(path, info, depth))
+					} else {
+						err = c.printf("%s\n", path)
+					}
+					if err != nil {
+						return gotfp.ActionExit
+					}
+					return gotfp.ActionContinue
+				}), roots...)
+			if werr := c.writeErr(); werr != nil {
+				return werr
+			}
+			return err
+		},
+	}
+}
+
+func duCommand() *tCommand {
+	var summarize, human bool
+	return &tCommand{
+		Name:    "du",
+		Args:    "[root ...]",
+		Summary: "Print the total apparent size of regular files in each directory, counting hard links once",
+		SetFlags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&summarize, "s", false, "print the totals of roots only")
+			fs.BoolVar(&human, "h", false, "print sizes in human-readable units")
+		},
+		Run: func(ctx context.Context, c *tCommon, args []string) error {
+			roots, err := rootsOnly(args)
+			if err != nil {
+				return err
+			}
+			opts := append(c.options(), gotfp.WithHardLinks(gotfp.SkipHardLinks))
+			result, err := gotfp.Reduce(ctx, gotfp.NewTraverser(opts...),
+				func(info gotfp.FileInfo, depth int) (int64, gotfp.Action) {
+					if info.Cat != gotfp.RegularFile {
+						return 0, gotfp.ActionContinue
+					}
+					fi, err := info.LoadInfo()
+					if err != nil {
+						return 0, gotfp.ActionContinue
+					}
+					return fi.Size(), gotfp.ActionContinue
+				},
+				func(x, y int64) int64 { return x + y },
+				roots...)
+			sizes := result.Dirs
+			if summarize {
+				sizes = result.Roots
+			} else {
+				for path, size := range result.Roots {
+					sizes[path] = size // Roots that are not directories.
+				}
+			}
+			paths := newPaths(roots)
+			for _, path := range sortedKeys(sizes) {
+				size := sizes[path]
+				path = paths.display(path)
+				var werr error
+				if c.JSON {
+					werr = c.printJSON(struct {
+						Path string `json:"path"`
+						Size int64  `json:"size"`
+					}{path, size})
+				} else if human {
+					werr = c.printf("%s\t%s\n", humanSize(size), path)
+				} else {
+					werr = c.printf("%d\t%s\n", size, path)
+				}
+				if werr != nil {
+					return werr
+				}
+			}
+			return err
+		},
+	}
+}
+
+// humanSize formats size in bytes with a binary unit, e.g., "1.5K".
+func humanSize(size int64) string {
+	const units = "KMGTPE"
+	if size < 1024 {
+		return fmt.Sprintf("%dB", size)
+	}
+	f, i := float64(size)/1024, 0
+	for f >= 1024 && i < len(units)-1 {
+		f, i = f/1024, i+1
+	}
+	if f < 10 {
+		return fmt.Sprintf("%.1f%c", f, units[i])
+	}
+	return fmt.Sprintf("%.0f%c", f, units[i])
+}
+
+func hashCommand() *tCommand {
+	var algNames, formatName, manifest string
+	return &tCommand{
+		Name: "hash",
+		Args: "[root ...]",
+		Summary: "Print checksums of regular files with paths relative to their root, " +
+			"or verify a root against a manifest",
+		SetFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&algNames, "a", "sha256",
+				"comma-separated algorithms: md5, sha1, sha256, sha512")
+			fs.StringVar(&formatName, "format", "gnu", "manifest format: gnu or bsd")
+			fs.StringVar(&manifest, "c", "",
+				"verify the root against the manifest file, in either format")
+		},
+		Run: func(ctx context.Context, c *tCommon, args []string) error {
+			roots, err := rootsOnly(args)
+			if err != nil {
+				return err
+			}
+			var algs []checksum.Algorithm
+			for _, name := range strings.Split(algNames, ",") {
+				alg := checksum.ParseAlgorithm(strings.TrimSpace(name))
+				if !alg.IsKnown() {
+					return usageErrorf("unknown algorithm %q", name)
+				}
+				algs = append(algs, alg)
+			}
+			format := checksum.ParseFormat(formatName)
+			if format == 0 {
+				return usageErrorf("unknown format %q", formatName)
+			}
+			h := checksum.NewHasher(checksum.WithAlgorithms(algs...),
+				checksum.WithWorkerNumber(c.Workers),
+				checksum.WithTraverserOptions(c.options()...))
+			if manifest != "" {
+				if len(roots) != 1 {
+					return usageErrorf("-c takes exactly one root")
+				}
+				return verify(ctx, c, h, roots[0], manifest)
+			}
+			var errs []error
+			for _, root := range roots {
+				if ctx.Err() != nil {
+					break
+				}
+				sums, err := h.Sum(ctx, root)
+				errs = append(errs, err)
+				if c.JSON {
+					for _, sum := range sums {
+						err := c.printJSON(struct {
+							Path    string                        `json:"path"`
+							Size    int64                         `json:"size"`
+							Digests map[checksum.Algorithm]string `json:"digests"`
+						}{sum.Path, sum.Size, sum.Digests})
+						if err != nil {
+							return err
+						}
+					}
+					continue
+				}
+				for _, alg := range algs {
+					if err := checksum.WriteManifest(c.w, sums, alg, format); err != nil {
+						return err
+					}
+				}
+			}
+			return errors.Join(errs...)
+		},
+	}
+}
+
+// verify verifies root against the manifest file, and prints the files
+// not matching it.
+func verify(ctx context.Context, c *tCommon, h *checksum.Hasher,
+	root, manifest string) error {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return err
+	}
+	entries, err := checksum.ParseManifest(f)
+	f.Close() // Ignore error.
+	if err != nil {
+		return err
+	}
+	report, err := h.Verify(ctx, root, entries)
+	for _, list := range []struct {
+		Status string
+		Paths  []string
+	}{
+		{"FAILED", report.Mismatched},
+		{"MISSING", report.Missing},
+		{"EXTRA", report.Extra},
+	} {
+		for _, path := range list.Paths {
+			var werr error
+			if c.JSON {
+				werr = c.printJSON(struct {
+					Path   string `json:"path"`
+					Status string `json:"status"`
+				}{path, list.Status})
+			} else {
+				werr = c.printf("%s: %s\n", path, list.Status)
+			}
+			if werr != nil {
+				return werr
+			}
+		}
+	}
+	if err == nil && !report.OK() {
+		err = fmt.Errorf("%d OK, %d failed, %d missing, %d extra",
+			report.NumOK, len(report.Mismatched), len(report.Missing),
+			len(report.Extra))
+	}
+	return err
+}
+
+func treeCommand() *tCommand {
+	var maxDepth int
+	return &tCommand{
+		Name:    "tree",
+		Args:    "[root ...]",
+		Summary: "Print files as a tree, in the order of name",
+		SetFlags: func(fs *flag.FlagSet) {
+			fs.IntVar(&maxDepth, "d", -1,
+				"descend at most this many levels; negative for no limit")
+		},
+		Run: func(ctx context.Context, c *tCommon, args []string) error {
+			roots, err := rootsOnly(args)
+			if err != nil {
+				return err
+			}
+			opts := append(c.options(),
+				gotfp.WithOrderedDelivery(4096), gotfp.WithMaxDepth(maxDepth))
+			paths := newPaths(roots)
+			err = gotfp.NewTraverser(opts...).Files(ctx,
+				func(info gotfp.FileInfo, depth int) gotfp.Action {
+					if c.JSON {
+						err := c.printJSON(newFileJSON(paths.display(info.Path),
+							info, depth))
+						if err != nil {
+							return gotfp.ActionExit
+						}
+						return gotfp.ActionContinue
+					}
+					name := paths.display(info.Path)
+					if depth > 0 {
+						name = filepath.Base(info.Path)
+					}
+					switch info.Cat {
+					case gotfp.Directory:
+						name += "/"
+					case gotfp.Symlink:
+						name += "@"
+					case gotfp.ErrorFile:
+						if info.Err != nil {
+							name += " [" + info.Err.Error() + "]"
+						}
+					}
+					if c.printf("%s%s\n", strings.Repeat("    ", depth), name) != nil {
+						return gotfp.ActionExit
+					}
+					return gotfp.ActionContinue
+				}, roots...)
+			if werr := c.writeErr(); werr != nil {
+				return werr
+			}
+			return err
+		},
+	}
+}
+
+func dupesCommand() *tCommand {
+	var minSize int64
+	var skipHardLinks bool
+	return &tCommand{
+		Name:    "dupes",
+		Args:    "[root ...]",
+		Summary: "Print groups of duplicate regular files, separated by blank lines",
+		SetFlags: func(fs *flag.FlagSet) {
+			fs.Int64Var(&minSize, "min-size", 1, "ignore files smaller than this many bytes")
+			fs.BoolVar(&skipHardLinks, "skip-hardlinks", false,
+				"do not report hard links to a file as its duplicates")
+		},
+		Run: func(ctx context.Context, c *tCommon, args []string) error {
+			roots, err := rootsOnly(args)
+			if err != nil {
+				return err
+			}
+			result, err := dedup.NewFinder(
+				dedup.WithTraverserOptions(c.options()...),
+				dedup.WithWorkerNumber(c.Workers),
+				dedup.WithMinSize(minSize),
+				dedup.WithSkipHardLinks(skipHardLinks),
+			).Find(ctx, roots...)
+			paths := newPaths(roots)
+			for i, g := range result.Groups {
+				for j := range g.Paths {
+					g.Paths[j] = paths.display(g.Paths[j])
+				}
+				if c.JSON {
+					if werr := c.printJSON(g); werr != nil {
+						return werr
+					}
+					continue
+				}
+				if i > 0 {
+					if werr := c.printf("\n"); werr != nil {
+						return werr
+					}
+				}
+				for _, path := range g.Paths {
+					if werr := c.printf("%s\n", path); werr != nil {
+						return werr
+					}
+				}
+			}
+			return err
+		},
+	}
+}
+
+// tStats is the output of the stats command.
+type tStats struct {
+	Files    int   `json:"files"`
+	Dirs     int   `json:"dirs"`
+	Symlinks int   `json:"symlinks"`
+	Others   int   `json:"others"`
+	Errors   int   `json:"errors"`
+	Size     int64 `json:"size"` // Total size of regular files.
+	MaxDepth int   `json:"max_depth"`
+}
+
+func statsCommand() *tCommand {
+	return &tCommand{
+		Name:    "stats",
+		Args:    "[root ...]",
+		Summary: "Print the numbers of files by type, the total size of regular files, and the maximum depth",
+		Run: func(ctx context.Context, c *tCommon, args []string) error {
+			roots, err := rootsOnly(args)
+			if err != nil {
+				return err
+			}
+			var mu sync.Mutex
+			var stats tStats
+			err = gotfp.NewTraverser(c.options()...).Files(ctx,
+				func(info gotfp.FileInfo, depth int) gotfp.Action {
+					var size int64
+					if info.Cat == gotfp.RegularFile {
+						if fi, err := info.LoadInfo(); err == nil {
+							size = fi.Size()
+						}
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					switch info.Cat {
+					case gotfp.RegularFile:
+						stats.Files++
+						stats.Size += size
+					case gotfp.Directory:
+						stats.Dirs++
+					case gotfp.Symlink:
+						stats.Symlinks++
+					case gotfp.OtherFile:
+						stats.Others++
+					case gotfp.ErrorFile:
+						stats.Errors++
+					}
+					if depth > stats.MaxDepth {
+						stats.MaxDepth = depth
+					}
+					return gotfp.ActionContinue
+				}, roots...)
+			var werr error
+			if c.JSON {
+				werr = c.printJSON(&stats)
+			} else {
+				werr = c.printf("files:     %d\ndirs:      %d\nsymlinks:  %d\nothers:    %d\n"+
+					"errors:    %d\nsize:      %d\nmax_depth: %d\n",
+					stats.Files, stats.Dirs, stats.Symlinks, stats.Others,
+					stats.Errors, stats.Size, stats.MaxDepth)
+			}
+			if werr != nil {
+				return werr
+			}
+			return err
+		},
+	}
+}
@@ -0,0 +1,346 @@
+// Command gotfp traverses files in parallel.
+//
+// Usage:
+//
+//	gotfp <command> [flags] [root ...] [arguments]
+//
+// The commands are:
+//
+//	find   print files matching a find(1)-style expression
+//	du     print the total size of regular files in directories
+//	hash   print or verify checksums of files
+//	tree   print files as a tree
+//	dupes  print groups of duplicate files
+//	stats  print the numbers and total size of files by type
+//
+// Roots default to the current directory.
+// Run "gotfp <command> -h" for the flags of a command.
+//
+// With -json, the output is newline-delimited JSON, a value per line.
+// It exits with status 1 if any error occurs, 2 for bad usage,
+// and 130 if interrupted, after printing what it has found.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/donyori/gotfp"
+)
+
+// Exit statuses.
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitUsage       = 2
+	exitInterrupted = 130
+)
+
+// tCommand is a subcommand.
+type tCommand struct {
+	Name    string
+	Args    string // Synopsis of the arguments after the flags.
+	Summary string
+	// Run runs the command with the parsed common flags and the arguments
+	// after the flags. Flags of the command are set by SetFlags.
+	Run      func(ctx context.Context, c *tCommon, args []string) error
+	SetFlags func(fs *flag.FlagSet)
+}
+
+var commands = []*tCommand{
+	findCommand(),
+	duCommand(),
+	hashCommand(),
+	treeCommand(),
+	dupesCommand(),
+	statsCommand(),
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(),
+		os.Interrupt, syscall.SIGTERM)
+	status := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(status)
+}
+
+// tUsageError is an error of bad usage.
+type tUsageError struct {
+	msg string
+}
+
+func (ue *tUsageError) Error() string {
+	return ue.msg
+}
+
+func usageErrorf(format string, a ...interface{}) error {
+	return &tUsageError{msg: fmt.Sprintf(format, a...)}
+}
+
+// run runs the command line args (without the program name),
+// and returns the exit status.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" ||
+		args[0] == "--help" || args[0] == "help" {
+		usage(stderr)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+	var cmd *tCommand
+	for _, c := range commands {
+		if c.Name == args[0] {
+			cmd = c
+			break
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "gotfp: unknown command %q\n", args[0])
+		usage(stderr)
+		return exitUsage
+	}
+	fs := flag.NewFlagSet("gotfp "+cmd.Name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: gotfp %s [flags] %s\n\n%s.\n\nFlags:\n",
+			cmd.Name, cmd.Args, cmd.Summary)
+		fs.PrintDefaults()
+	}
+	c := new(tCommon)
+	c.setFlags(fs)
+	if cmd.SetFlags != nil {
+		cmd.SetFlags(fs)
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	c.w = bufio.NewWriter(stdout)
+	err := cmd.Run(ctx, c, fs.Args())
+	if flushErr := c.w.Flush(); err == nil {
+		err = flushErr
+	}
+	var ue *tUsageError
+	switch {
+	case errors.As(err, &ue):
+		fmt.Fprintf(stderr, "gotfp %s: %v\n", cmd.Name, err)
+		fs.Usage()
+		return exitUsage
+	case ctx.Err() != nil:
+		if err != nil && !errors.Is(err, ctx.Err()) {
+			fmt.Fprintf(stderr, "gotfp %s: %v\n", cmd.Name, err)
+		}
+		fmt.Fprintf(stderr, "gotfp %s: interrupted\n", cmd.Name)
+		return exitInterrupted
+	case err != nil:
+		fmt.Fprintf(stderr, "gotfp %s: %v\n", cmd.Name, err)
+		return exitError
+	}
+	return exitOK
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: gotfp <command> [flags] [root ...] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-6s %s\n", c.Name, c.Summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "gotfp <command> -h" for the flags of a command.`)
+}
+
+// tStrings is a flag.Value of strings, by repeating the flag.
+type tStrings []string
+
+func (s *tStrings) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *tStrings) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// tCommon holds the flags common to all commands, and the output.
+type tCommon struct {
+	Workers  int
+	JSON     bool
+	Excludes tStrings
+	Follow   bool
+	SameFS   bool
+
+	w   *bufio.Writer
+	err error      // The first error in writing to w.
+	mu  sync.Mutex // Lock for w and err.
+}
+
+func (c *tCommon) setFlags(fs *flag.FlagSet) {
+	fs.IntVar(&c.Workers, "workers", 0,
+		"number of workers; non-positive for GOMAXPROCS")
+	fs.BoolVar(&c.JSON, "json", false, "write newline-delimited JSON")
+	fs.Var(&c.Excludes, "exclude",
+		"exclude files matching the glob pattern, relative to the root (repeatable)")
+	fs.BoolVar(&c.Follow, "L", false, "follow symbolic links")
+	fs.BoolVar(&c.SameFS, "x", false, "stay on the file systems of the roots")
+}
+
+// options returns the options of Traverser by the common flags.
+func (c *tCommon) options() []gotfp.Option {
+	opts := []gotfp.Option{
+		gotfp.WithWorkerNumber(c.Workers),
+		gotfp.WithFollowSymlinks(c.Follow),
+		gotfp.WithSameFileSystem(c.SameFS),
+	}
+	if len(c.Excludes) > 0 {
+		opts = append(opts, gotfp.WithExclude(c.Excludes...))
+	}
+	return opts
+}
+
+// printf writes a line of text. It is safe for concurrent use.
+// It returns the first error in writing, after which nothing is written.
+func (c *tCommon) printf(format string, a ...interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		_, c.err = fmt.Fprintf(c.w, format, a...)
+	}
+	return c.err
+}
+
+// printJSON writes v as a line of JSON. It is safe for concurrent use.
+// It returns the first error in writing, after which nothing is written.
+func (c *tCommon) printJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		if c.err = err; err == nil {
+			b = append(b, '\n')
+			_, c.err = c.w.Write(b)
+		}
+	}
+	return c.err
+}
+
+// writeErr returns the first error in writing, if any.
+func (c *tCommon) writeErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// splitRoots splits args into the roots and the rest, which starts with
+// the first argument that looks like a flag or an operator of
+// an expression. Roots default to the current directory.
+func splitRoots(args []string) (roots, rest []string) {
+	i := 0
+	for ; i < len(args); i++ {
+		if a := args[i]; strings.HasPrefix(a, "-") || a == "!" || a == "(" {
+			break
+		}
+	}
+	roots, rest = args[:i], args[i:]
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+	return
+}
+
+// rootsOnly returns the roots in args, or a usage error if there are other
+// arguments, e.g., a flag after a root.
+func rootsOnly(args []string) ([]string, error) {
+	roots, rest := splitRoots(args)
+	if len(rest) > 0 {
+		return nil, usageErrorf("unexpected argument %q; flags must precede roots", rest[0])
+	}
+	return roots, nil
+}
+
+// tPaths maps the absolute paths reported by Traverser back to
+// the roots as given, as find(1) prints them.
+type tPaths struct {
+	roots []string
+	abs   []string
+}
+
+func newPaths(roots []string) *tPaths {
+	p := &tPaths{roots: roots, abs: make([]string, len(roots))}
+	for i, root := range roots {
+		var err error
+		if p.abs[i], err = filepath.Abs(root); err != nil {
+			p.abs[i] = filepath.Clean(root)
+		}
+	}
+	return p
+}
+
+// display returns path under the root as given.
+// If several roots contain path, the deepest one is used.
+func (p *tPaths) display(path string) string {
+	const sep = string(filepath.Separator)
+	best := -1
+	for i, abs := range p.abs {
+		if path != abs && !strings.HasPrefix(path, strings.TrimSuffix(abs, sep)+sep) {
+			continue
+		}
+		if best < 0 || len(abs) > len(p.abs[best]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return path
+	}
+	root, rest := p.roots[best], path[len(p.abs[best]):]
+	rest = strings.TrimPrefix(rest, sep)
+	if rest == "" {
+		return root
+	}
+	if !strings.HasSuffix(root, sep) {
+		root += sep
+	}
+	return root + rest
+}
+
+// typeName returns the name of cat in the output.
+func typeName(cat gotfp.FileCategory) string {
+	switch cat {
+	case gotfp.RegularFile:
+		return "file"
+	case gotfp.Directory:
+		return "dir"
+	case gotfp.Symlink:
+		return "symlink"
+	case gotfp.OtherFile:
+		return "other"
+	case gotfp.ErrorFile:
+		return "error"
+	}
+	return "unknown"
+}
+
+// sortedKeys returns the keys of m, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
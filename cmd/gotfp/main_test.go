@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/donyori/gotfp/internal/testfs"
+)
+
+// testRun runs the command line args, and returns the exit status
+// and the output, with root replaced by "ROOT".
+func testRun(tb testing.TB, ctx context.Context, root string, args ...string) (
+	status int, stdout, stderr string) {
+	tb.Helper()
+	var outBuf, errBuf bytes.Buffer
+	status = run(ctx, args, &outBuf, &errBuf)
+	stdout = strings.ReplaceAll(filepath.ToSlash(outBuf.String()), filepath.ToSlash(root), "ROOT")
+	stderr = strings.ReplaceAll(filepath.ToSlash(errBuf.String()), filepath.ToSlash(root), "ROOT")
+	return
+}
+
+var testFiles = map[string]string{
+	"a.go":     "package a",
+	"b/c.txt":  "hello",
+	"b/d/e.go": "package d",
+	"dup1":     "same",
+	"x/dup2":   "same",
+}
+
+func TestRun(t *testing.T) {
+	root := testfs.MakeTree(t, testFiles)
+	ctx := context.Background()
+	testCases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"find", root, "-name", "*.go"}, "ROOT/a.go\nROOT/b/d/e.go\n"},
+		{[]string{"find", "-workers", "1", root, "-type", "d", "-name", "d", "-o", "-path", "*/x"},
+			"ROOT/b/d\nROOT/x\n"},
+		{[]string{"du", "-s", root}, "31\tROOT\n"},
+		{[]string{"du", "-exclude", "b", root}, "17\tROOT\n4\tROOT/x\n"},
+		{[]string{"tree", root}, "ROOT/\n    a.go\n    b/\n        c.txt\n        d/\n" +
+			"            e.go\n    dup1\n    x/\n        dup2\n"},
+		{[]string{"tree", "-d", "1", "-exclude", "x", root}, "ROOT/\n    a.go\n    b/\n    dup1\n"},
+		{[]string{"dupes", root}, "ROOT/dup1\nROOT/x/dup2\n"},
+		{[]string{"stats", root}, "files:     5\ndirs:      4\nsymlinks:  0\nothers:    0\n" +
+			"errors:    0\nsize:      31\nmax_depth: 3\n"},
+	}
+	for _, tc := range testCases {
+		status, stdout, stderr := testRun(t, ctx, root, tc.args...)
+		if status != exitOK {
+			t.Errorf("%q: got status %d; stderr:\n%s", tc.args, status, stderr)
+		}
+		if stdout != tc.want {
+			t.Errorf("%q: got\n%s\nwant\n%s", tc.args, stdout, tc.want)
+		}
+	}
+}
+
+func TestRun_FindPath(t *testing.T) {
+	root := testfs.MakeTree(t, testFiles)
+	t.Chdir(root)
+	status, stdout, stderr := testRun(t, context.Background(), root,
+		"find", ".", "-path", "./b", "-prune", "-o", "-path", "./*", "-print")
+	if status != exitOK {
+		t.Errorf("got status %d; stderr:\n%s", status, stderr)
+	}
+	lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	sort.Strings(lines)
+	want := []string{"./a.go", "./dup1", "./x", "./x/dup2"}
+	if strings.Join(lines, ",") != strings.Join(want, ",") {
+		t.Errorf("got %q; want %q", lines, want)
+	}
+}
+
+func TestRun_JSON(t *testing.T) {
+	root := testfs.MakeTree(t, testFiles)
+	status, stdout, stderr := testRun(t, context.Background(), root,
+		"find", "-json", root, "-name", "*.txt", "-o", "-name", "d")
+	if status != exitOK {
+		t.Fatalf("got status %d; stderr:\n%s", status, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines; want 2:\n%s", len(lines), stdout)
+	}
+	types := make(map[string]string)
+	for _, line := range lines {
+		var f tFileJSON
+		if err := json.Unmarshal([]byte(line), &f); err != nil {
+			t.Fatal(err)
+		}
+		types[f.Path] = f.Type
+		if f.Depth != 2 {
+			t.Errorf("%s: got depth %d; want 2", f.Path, f.Depth)
+		}
+	}
+	if types["ROOT/b/c.txt"] != "file" || types["ROOT/b/d"] != "dir" {
+		t.Errorf("got %v", types)
+	}
+}
+
+func TestRun_Hash(t *testing.T) {
+	root := testfs.MakeTree(t, testFiles)
+	ctx := context.Background()
+	status, manifest, stderr := testRun(t, ctx, root, "hash", "-format", "bsd", root)
+	if status != exitOK {
+		t.Fatalf("got status %d; stderr:\n%s", status, stderr)
+	}
+	if !strings.HasPrefix(manifest, "SHA256 (a.go) = ") ||
+		strings.Count(manifest, "\n") != len(testFiles) {
+		t.Errorf("got manifest\n%s", manifest)
+	}
+	name := filepath.Join(t.TempDir(), "SHA256SUMS")
+	if err := os.WriteFile(name, []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	status, stdout, stderr := testRun(t, ctx, root, "hash", "-c", name, root)
+	if status != exitOK || stdout != "" {
+		t.Errorf("got status %d; stdout:\n%s\nstderr:\n%s", status, stdout, stderr)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a.go"), []byte("package b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(root, "dup1")); err != nil {
+		t.Fatal(err)
+	}
+	status, stdout, _ = testRun(t, ctx, root, "hash", "-c", name, root)
+	if want := "a.go: FAILED\ndup1: MISSING\n"; status != exitError || stdout != want {
+		t.Errorf("got status %d, output\n%s\nwant %d, output\n%s", status, stdout, exitError, want)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	root := testfs.MakeTree(t, testFiles)
+	ctx := context.Background()
+	testCases := []struct {
+		args   []string
+		status int
+		want   string // Substring of stderr.
+	}{
+		{nil, exitUsage, "usage: gotfp <command>"},
+		{[]string{"help"}, exitOK, "Commands:"},
+		{[]string{"bogus"}, exitUsage, `unknown command "bogus"`},
+		{[]string{"find", "-bogus-flag"}, exitUsage, "flag provided but not defined"},
+		{[]string{"find", root, "-nmae", "x"}, exitUsage, "did you mean -name?"},
+		{[]string{"du", root, "-s"}, exitUsage, "flags must precede roots"},
+		{[]string{"hash", "-a", "crc32", root}, exitUsage, `unknown algorithm "crc32"`},
+		{[]string{"hash", "-c", "none", root, root}, exitUsage, "exactly one root"},
+		{[]string{"stats", filepath.Join(root, "none")}, exitError, "no such file"},
+	}
+	for _, tc := range testCases {
+		status, _, stderr := testRun(t, ctx, root, tc.args...)
+		if status != tc.status {
+			t.Errorf("%q: got status %d; want %d", tc.args, status, tc.status)
+		}
+		if !strings.Contains(stderr, tc.want) {
+			t.Errorf("%q: got stderr\n%s\nwant it to contain %q", tc.args, stderr, tc.want)
+		}
+	}
+}
+
+func TestRun_Interrupted(t *testing.T) {
+	root := testfs.MakeTree(t, testFiles)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, args := range [][]string{
+		{"stats", root},
+		{"hash", root},
+		{"hash", "-json", root, root},
+	} {
+		status, _, stderr := testRun(t, ctx, root, args...)
+		if status != exitInterrupted || !strings.Contains(stderr, "interrupted") {
+			t.Errorf("%q: got status %d, stderr\n%s", args, status, stderr)
+		}
+	}
+}
+
+// testErrWriter fails all writes, and counts them.
+type testErrWriter struct {
+	n int
+}
+
+func (w *testErrWriter) Write(p []byte) (n int, err error) {
+	w.n++
+	return 0, errors.New("test write error")
+}
+
+func TestRun_WriteError(t *testing.T) {
+	files := make(map[string]string)
+	for i := 0; i < 500; i++ {
+		files[fmt.Sprintf("d/file%03d", i)] = "x"
+	}
+	root := testfs.MakeTree(t, files)
+	for _, args := range [][]string{
+		{"find", root},
+		{"find", "-json", root},
+		{"du", "-json", root},
+		{"hash", root},
+		{"hash", "-json", root},
+		{"tree", root},
+		{"stats", "-json", root},
+	} {
+		var w testErrWriter
+		var stderr bytes.Buffer
+		status := run(context.Background(), args, &w, &stderr)
+		if status != exitError || !strings.Contains(stderr.String(), "test write error") {
+			t.Errorf("%q: got status %d, stderr\n%s", args, status, stderr.String())
+		}
+		if w.n != 1 {
+			t.Errorf("%q: got %d writes; want 1", args, w.n)
+		}
+	}
+}
+
+func TestPaths_Display(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newPaths([]string{".", "sub", "sub/deep/"})
+	testCases := []struct {
+		path, want string
+	}{
+		{wd, "."},
+		{filepath.Join(wd, "a"), "." + string(filepath.Separator) + "a"},
+		{filepath.Join(wd, "sub", "b"), filepath.Join("sub", "b")},
+		{filepath.Join(wd, "sub", "deep", "c"), filepath.Join("sub", "deep", "c")},
+		{filepath.Join(wd, "subway"), "." + string(filepath.Separator) + "subway"},
+	}
+	for _, tc := range testCases {
+		if got := p.display(tc.path); got != tc.want {
+			t.Errorf("%q: got %q; want %q", tc.path, got, tc.want)
+		}
+	}
+}
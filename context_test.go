@@ -0,0 +1,74 @@
+package gotfp
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/donyori/goctpf"
+)
+
+func TestTraverseFilesContext_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var counter, counterAfterCancel int64
+	const limit = 100
+	handler := func(info FileInfo, depth int) Action {
+		if atomic.AddInt64(&counter, 1) == limit {
+			cancel()
+		} else if ctx.Err() != nil {
+			atomic.AddInt64(&counterAfterCancel, 1)
+		}
+		return ActionContinue
+	}
+	err := TraverseFilesContext(ctx, handler, goctpf.WorkerSettings{
+		Number: uint32(testMaxProcs),
+	}, nil, testRoot)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v; want %v", err, context.Canceled)
+	}
+	// Each worker may finish at most one task after cancellation.
+	if n := atomic.LoadInt64(&counterAfterCancel); n > int64(testMaxProcs) {
+		t.Errorf("handler called %d times after cancellation", n)
+	}
+}
+
+func TestTraverseBatchesContext_Deadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	var counter int64
+	handler := func(batch Batch, depth int) (Action, map[string]bool) {
+		atomic.AddInt64(&counter, 1)
+		return ActionContinue, nil
+	}
+	err := TraverseBatchesContext(ctx, handler, goctpf.WorkerSettings{
+		Number: uint32(testMaxProcs),
+	}, nil, testRoot)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v; want %v", err, context.DeadlineExceeded)
+	}
+	if n := atomic.LoadInt64(&counter); n != 0 {
+		t.Errorf("handler called %d times; want 0", n)
+	}
+}
+
+func TestTraverseFilesWithBatchContext_NoCancel(t *testing.T) {
+	var counter int64
+	handler := func(info FileInfo, lctn *LocationBatchInfo, depth int) Action {
+		atomic.AddInt64(&counter, 1)
+		if depth > 0 {
+			return ActionSkip
+		}
+		return ActionContinue
+	}
+	err := TraverseFilesWithBatchContext(context.Background(), handler,
+		goctpf.WorkerSettings{Number: uint32(testMaxProcs)}, nil, testRoot)
+	if err != nil {
+		t.Error(err)
+	}
+	if atomic.LoadInt64(&counter) == 0 {
+		t.Error("handler not called")
+	}
+}
@@ -0,0 +1,396 @@
+// Package dedup finds duplicate files with gotfp.
+//
+// Regular files are first grouped by size from the batches of
+// Traverser.Batches. Files of a size shared by others are then narrowed
+// down by a partial hash of their first and last blocks, and at last
+// by a hash of their whole contents. Hashes are computed by a pool of
+// workers, as many as the workers of the traversal, and files are read
+// through the Traverser, so from the file system it traverses
+// (see gotfp.WithFS).
+package dedup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/donyori/gotfp"
+)
+
+// DefaultBlockSize is the default size of the blocks in the partial hash.
+const DefaultBlockSize = 4096
+
+// Group is a group of duplicate files.
+type Group struct {
+	Size  int64    `json:"size"`  // Size of each file, in bytes.
+	Hash  string   `json:"hash"`  // Hex-encoded SHA-256 of the contents.
+	Paths []string `json:"paths"` // Paths of the files, sorted.
+}
+
+// Wasted returns the bytes taken by the duplicates in g,
+// i.e., all files but one.
+func (g *Group) Wasted() int64 {
+	if len(g.Paths) < 2 {
+		return 0
+	}
+	return g.Size * int64(len(g.Paths)-1)
+}
+
+// Result is the result of Finder.Find.
+type Result struct {
+	// Groups are the groups of duplicate files, in descending order of size
+	// and then in ascending order of the first path.
+	Groups []*Group `json:"groups"`
+	// NumFiles is the number of regular files found.
+	NumFiles int `json:"num_files"`
+	// NumCandidates is the number of files of a size shared by others,
+	// which are hashed.
+	NumCandidates int `json:"num_candidates"`
+	// Wasted is the bytes taken by the duplicates in all groups.
+	Wasted int64 `json:"wasted"`
+}
+
+// WriteJSON writes r to w as an indented JSON object.
+func (r *Result) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+type config struct {
+	traverserOpts []gotfp.Option
+	minSize       int64
+	blockSize     int64
+	skipHardLinks bool
+	workerNumber  int
+}
+
+// Finder finds duplicate files.
+// It is configured by options when created and can be reused,
+// also concurrently.
+type Finder struct {
+	cfg config
+}
+
+// Option configures a Finder.
+type Option func(cfg *config)
+
+// NewFinder creates a Finder with given options.
+//
+// By default, it ignores empty files, uses the default options of
+// gotfp.NewTraverser, and hashes blocks of DefaultBlockSize bytes
+// in the partial hash.
+func NewFinder(opts ...Option) *Finder {
+	f := &Finder{cfg: config{minSize: 1, blockSize: DefaultBlockSize}}
+	for _, opt := range opts {
+		opt(&f.cfg)
+	}
+	return f
+}
+
+// WithTraverserOptions adds options of the Traverser to find files,
+// e.g., gotfp.WithExclude and gotfp.WithSameFileSystem.
+func WithTraverserOptions(opts ...gotfp.Option) Option {
+	return func(cfg *config) {
+		cfg.traverserOpts = append(cfg.traverserOpts, opts...)
+	}
+}
+
+// WithWorkerNumber sets the number of workers, both to find and to hash
+// files. Non-positive n stands for GOMAXPROCS.
+// It overrides gotfp.WithWorkerNumber in WithTraverserOptions.
+func WithWorkerNumber(n int) Option {
+	return func(cfg *config) {
+		cfg.workerNumber = n
+	}
+}
+
+// WithMinSize sets the minimum size of files to compare, in bytes.
+// Smaller files are ignored. Non-positive n stands for 1,
+// i.e., empty files are always ignored.
+func WithMinSize(n int64) Option {
+	return func(cfg *config) {
+		if n < 1 {
+			n = 1
+		}
+		cfg.minSize = n
+	}
+}
+
+// WithBlockSize sets the size of the first and last blocks hashed
+// in the partial hash. Files no larger than two blocks are hashed
+// as a whole at once.
+//
+// It panics if n is not positive.
+func WithBlockSize(n int64) Option {
+	return func(cfg *config) {
+		if n <= 0 {
+			panic(errors.New("dedup: block size is not positive"))
+		}
+		cfg.blockSize = n
+	}
+}
+
+// WithSkipHardLinks sets whether to report a file with several hard links
+// only once (see gotfp.SkipHardLinks), so that the hard links to a file
+// are not reported as its duplicates.
+func WithSkipHardLinks(skip bool) Option {
+	return func(cfg *config) {
+		cfg.skipHardLinks = skip
+	}
+}
+
+// tFile is a candidate file.
+type tFile struct {
+	Path string
+	Size int64
+	Hash string // Partial hash, or full hash if IsFull.
+
+	IsFull bool
+}
+
+// Find finds duplicate files in roots.
+//
+// The result is returned even if an error occurs. Files that cannot
+// be read are left out. The error joins the one returned by
+// gotfp.Traverser.Batches and those of reading files,
+// or is ctx.Err() if ctx is done.
+func (f *Finder) Find(ctx context.Context, roots ...string) (*Result, error) {
+	opts := make([]gotfp.Option, 0, len(f.cfg.traverserOpts)+2)
+	opts = append(opts, f.cfg.traverserOpts...)
+	opts = append(opts, gotfp.WithWorkerNumber(f.cfg.workerNumber))
+	if f.cfg.skipHardLinks {
+		opts = append(opts, gotfp.WithHardLinks(gotfp.SkipHardLinks))
+	}
+	t := gotfp.NewTraverser(opts...)
+	result := new(Result)
+	var mu sync.Mutex
+	bySize := make(map[int64][]*tFile)
+	err := t.Batches(ctx,
+		func(batch gotfp.Batch, depth int) (gotfp.Action, map[string]bool) {
+			infos := batch.RegFiles
+			if depth == 0 && batch.Parent.Cat == gotfp.RegularFile {
+				// A root that is a file has a batch of its own.
+				infos = append(infos[:len(infos):len(infos)], batch.Parent)
+			}
+			// Load the info of files before locking, which may stat them.
+			files := make([]*tFile, 0, len(infos))
+			for _, info := range infos {
+				fi, err := info.LoadInfo()
+				if err != nil || fi.Size() < f.cfg.minSize {
+					continue
+				}
+				files = append(files, &tFile{Path: info.Path, Size: fi.Size()})
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			result.NumFiles += len(infos)
+			for _, file := range files {
+				bySize[file.Size] = append(bySize[file.Size], file)
+			}
+			return gotfp.ActionContinue, nil
+		}, roots...)
+	errs := []error{err}
+	if ctx.Err() != nil {
+		return result, err
+	}
+
+	var candidates []*tFile
+	for _, files := range bySize {
+		if len(files) > 1 {
+			candidates = append(candidates, files...)
+		}
+	}
+	result.NumCandidates = len(candidates)
+	// The first pass computes the partial hashes,
+	// and the second pass the full hashes of the rest.
+	for pass := 0; pass < 2 && len(candidates) > 0 && ctx.Err() == nil; pass++ {
+		candidates, err = f.hashAll(ctx, t, candidates)
+		errs = append(errs, err)
+	}
+	if ctx.Err() != nil {
+		return result, errors.Join(errs...)
+	}
+	for _, g := range group(candidates) {
+		result.Groups = append(result.Groups, g)
+		result.Wasted += g.Wasted()
+	}
+	return result, errors.Join(errs...)
+}
+
+// hashAll hashes the files not hashed as a whole yet, opened by t,
+// and returns the files sharing their size and hash with others.
+// Files that cannot be read are dropped, with their errors returned.
+func (f *Finder) hashAll(ctx context.Context, t *gotfp.Traverser,
+	files []*tFile) ([]*tFile, error) {
+	var todo []*tFile
+	for _, file := range files {
+		if !file.IsFull {
+			todo = append(todo, file)
+		}
+	}
+	if len(todo) == 0 {
+		return files, nil
+	}
+	n := f.cfg.workerNumber
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	if n > len(todo) {
+		n = len(todo)
+	}
+	jobs := make(chan *tFile)
+	var mu sync.Mutex
+	var errs []error
+	failed := make(map[*tFile]bool)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for file := range jobs {
+				// Each file is hashed by only one worker.
+				hash, isFull, err := f.hash(t, file)
+				if err == nil {
+					file.Hash, file.IsFull = hash, isFull
+					continue
+				}
+				mu.Lock()
+				failed[file] = true
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for _, file := range todo {
+		select {
+		case jobs <- file:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Join(append(errs, err)...)
+	}
+	var kept []*tFile
+	for _, file := range files {
+		if !failed[file] {
+			kept = append(kept, file)
+		}
+	}
+	return shared(kept), errors.Join(errs...)
+}
+
+// hash computes the hash of file, opened by t. It computes the partial hash
+// if file has no hash yet and is larger than two blocks,
+// and otherwise the full hash.
+// isFull reports whether the hash is of the whole contents.
+func (f *Finder) hash(t *gotfp.Traverser, file *tFile) (
+	hash string, isFull bool, err error) {
+	r, err := t.Open(file.Path)
+	if err != nil {
+		return "", false, err
+	}
+	defer r.Close() // Ignore error.
+	h := sha256.New()
+	bs := f.cfg.blockSize
+	var n int64
+	if file.Hash != "" || file.Size <= 2*bs {
+		isFull = true
+		n, err = io.Copy(h, r)
+		if err == nil && n != file.Size {
+			err = io.ErrUnexpectedEOF // The file has been changed.
+		}
+	} else {
+		n, err = io.CopyN(h, r, bs)
+		if err == nil {
+			// Skip to the last block, by seeking if possible.
+			if s, ok := r.(io.Seeker); ok {
+				_, err = s.Seek(file.Size-bs, io.SeekStart)
+			} else {
+				_, err = io.CopyN(io.Discard, r, file.Size-2*bs)
+			}
+		}
+		if err == nil {
+			var m int64
+			m, err = io.CopyN(h, r, bs)
+			n += m
+		}
+		if errors.Is(err, io.EOF) || err == nil && n != 2*bs {
+			err = io.ErrUnexpectedEOF // The file has been changed.
+		}
+	}
+	if err != nil {
+		if !errors.As(err, new(*fs.PathError)) {
+			err = &fs.PathError{Op: "read", Path: file.Path, Err: err}
+		}
+		return "", false, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), isFull, nil
+}
+
+// group groups files by size and hash,
+// and returns the groups of more than one file.
+func group(files []*tFile) []*Group {
+	type key struct {
+		Size int64
+		Hash string
+	}
+	m := make(map[key]*Group)
+	var groups []*Group
+	for _, file := range files {
+		k := key{Size: file.Size, Hash: file.Hash}
+		g := m[k]
+		if g == nil {
+			g = &Group{Size: file.Size, Hash: file.Hash}
+			m[k] = g
+			groups = append(groups, g)
+		}
+		g.Paths = append(g.Paths, file.Path)
+	}
+	var i int
+	for _, g := range groups {
+		if len(g.Paths) > 1 {
+			sort.Strings(g.Paths)
+			groups[i] = g
+			i++
+		}
+	}
+	groups = groups[:i]
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Size != groups[j].Size {
+			return groups[i].Size > groups[j].Size
+		}
+		return groups[i].Paths[0] < groups[j].Paths[0]
+	})
+	return groups
+}
+
+// shared returns the files sharing their size and hash with others.
+func shared(files []*tFile) []*tFile {
+	type key struct {
+		Size int64
+		Hash string
+	}
+	counts := make(map[key]int)
+	for _, file := range files {
+		counts[key{Size: file.Size, Hash: file.Hash}]++
+	}
+	var kept []*tFile
+	for _, file := range files {
+		if counts[key{Size: file.Size, Hash: file.Hash}] > 1 {
+			kept = append(kept, file)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,197 @@
+package dedup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/donyori/gotfp"
+	"github.com/donyori/gotfp/internal/testfs"
+)
+
+// testPaths returns the slash-separated paths of each group relative to root.
+func testPaths(tb testing.TB, root string, groups []*Group) [][]string {
+	tb.Helper()
+	var paths [][]string
+	for _, g := range groups {
+		var ps []string
+		for _, p := range g.Paths {
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				tb.Fatal(err)
+			}
+			ps = append(ps, filepath.ToSlash(rel))
+		}
+		paths = append(paths, ps)
+	}
+	return paths
+}
+
+func TestFinder_Find(t *testing.T) {
+	root := testfs.MakeTree(t, map[string]string{
+		"a/big1":     "0123456789abcdef",
+		"b/big2":     "0123456789abcdef",
+		"c/big3":     "0123456789abcdef",
+		"mid":        "0123456X89abcdef", // Same size, first and last blocks.
+		"head":       "X123456789abcdef", // Same size, different first block.
+		"a/small1":   "xyz",
+		"b/small2":   "xyz",
+		"small3":     "xyy",
+		"empty1":     "",
+		"a/empty2":   "",
+		"unique.txt": "unique",
+	})
+	testCases := []struct {
+		name string
+		opts []Option
+	}{
+		{"default", nil},
+		{"small-blocks", []Option{WithBlockSize(4), WithWorkerNumber(4)}},
+		{"one-worker", []Option{WithBlockSize(2), WithWorkerNumber(1)}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := NewFinder(tc.opts...).Find(context.Background(), root)
+			if err != nil {
+				t.Error(err)
+			}
+			want := [][]string{{"a/big1", "b/big2", "c/big3"}, {"a/small1", "b/small2"}}
+			if got := testPaths(t, root, result.Groups); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v; want %v", got, want)
+			}
+			if result.NumFiles != 11 {
+				t.Errorf("got %d files; want 11", result.NumFiles)
+			}
+			if result.NumCandidates != 8 {
+				t.Errorf("got %d candidates; want 8", result.NumCandidates)
+			}
+			if want := int64(16*2 + 3); result.Wasted != want {
+				t.Errorf("got wasted %d; want %d", result.Wasted, want)
+			}
+		})
+	}
+}
+
+func TestFinder_Find_Options(t *testing.T) {
+	root := testfs.MakeTree(t, map[string]string{
+		"a/x":     "abc",
+		"b/x":     "abc",
+		"b/y":     "abc",
+		"c.txt":   "abcdef",
+		"d.txt":   "abcdef",
+		"e/empty": "",
+		"f/empty": "",
+	})
+	result, err := NewFinder(WithMinSize(4)).Find(context.Background(), root)
+	if err != nil {
+		t.Error(err)
+	}
+	if got, want := testPaths(t, root, result.Groups), [][]string{{"c.txt", "d.txt"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("min size: got %v; want %v", got, want)
+	}
+	result, err = NewFinder(WithTraverserOptions(gotfp.WithExclude("y"))).Find(
+		context.Background(), filepath.Join(root, "a", "x"), filepath.Join(root, "b"))
+	if err != nil {
+		t.Error(err)
+	}
+	if got, want := testPaths(t, root, result.Groups), [][]string{{"a/x", "b/x"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("file root and exclude: got %v; want %v", got, want)
+	}
+}
+
+func TestFinder_Find_FollowSymlinks(t *testing.T) {
+	root := testfs.MakeTree(t, map[string]string{
+		"a/x":        "0123456789abcdef",
+		"other/copy": "0123456789abcdef",
+	})
+	if err := os.Symlink(filepath.Join("..", "other", "copy"),
+		filepath.Join(root, "a", "link")); err != nil {
+		t.Skip("symbolic links unsupported:", err)
+	}
+	result, err := NewFinder(WithBlockSize(4),
+		WithTraverserOptions(gotfp.WithFollowSymlinks(true))).Find(
+		context.Background(), filepath.Join(root, "a"))
+	if err != nil {
+		t.Error(err)
+	}
+	if got, want := testPaths(t, root, result.Groups), [][]string{{"a/link", "a/x"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestFinder_Find_FS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/x":   {Data: []byte("0123456789abcdef")},
+		"b/x":   {Data: []byte("0123456789abcdef")},
+		"b/y":   {Data: []byte("0123456X89abcdef")},
+		"c.txt": {Data: []byte("abc")},
+	}
+	result, err := NewFinder(WithBlockSize(4),
+		WithTraverserOptions(gotfp.WithFS(fsys))).Find(
+		context.Background(), ".")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(result.Groups) != 1 || !reflect.DeepEqual(result.Groups[0].Paths, []string{"a/x", "b/x"}) {
+		t.Errorf("got groups %v; want one of [a/x b/x]", result.Groups)
+	}
+}
+
+func TestFinder_Find_HardLinks(t *testing.T) {
+	root := testfs.MakeTree(t, map[string]string{"a": "abc", "b": "abc"})
+	if err := os.Link(filepath.Join(root, "a"), filepath.Join(root, "c")); err != nil {
+		t.Skip("hard links unsupported:", err)
+	}
+	for _, skip := range []bool{false, true} {
+		result, err := NewFinder(WithSkipHardLinks(skip)).Find(context.Background(), root)
+		if err != nil {
+			t.Error(err)
+		}
+		if len(result.Groups) != 1 {
+			t.Errorf("skip %t: got %d groups; want 1", skip, len(result.Groups))
+			continue
+		}
+		n := len(result.Groups[0].Paths)
+		want := 3
+		if skip {
+			want = 2
+		}
+		if n != want {
+			t.Errorf("skip %t: got %d paths; want %d", skip, n, want)
+		}
+	}
+}
+
+func TestResult_WriteJSON(t *testing.T) {
+	result := &Result{
+		Groups:        []*Group{{Size: 3, Hash: "ab", Paths: []string{"x", "y"}}},
+		NumFiles:      5,
+		NumCandidates: 2,
+		Wasted:        3,
+	}
+	var buf bytes.Buffer
+	if err := result.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got Result
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, result) {
+		t.Errorf("got %+v; want %+v", got, *result)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"groups", "num_files", "num_candidates", "wasted"} {
+		if _, ok := m[key]; !ok {
+			t.Errorf("no %q in %s", key, buf.String())
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package gotfp
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWithMaxDepth(t *testing.T) {
+	testCases := []struct {
+		name      string
+		opts      []Option
+		wantFiles string
+		wantReads string
+	}{
+		{"max=0", []Option{WithMaxDepth(0)}, ".", ""},
+		{"max=1", []Option{WithMaxDepth(1)}, ".,keep,skip", "."},
+		{"min=1,max=1", []Option{WithMinDepth(1), WithMaxDepth(1)},
+			"keep,skip", "."},
+		{"min=2,max=2", []Option{WithMinDepth(2), WithMaxDepth(2)},
+			"keep/e,keep/g.txt,skip/a,skip/d.txt", ".,keep,skip"},
+		{"min=2,max=2,ordered", []Option{WithMinDepth(2), WithMaxDepth(2),
+			WithOrderedDelivery(100)},
+			"keep/e,keep/g.txt,skip/a,skip/d.txt", ".,keep,skip"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, method := range []string{"Files", "FilesWithBatch"} {
+				fsys := testMakeReadDirCounter()
+				tr := NewTraverser(append(tc.opts, WithFS(fsys))...)
+				var mu sync.Mutex
+				var files []string
+				record := func(info FileInfo) {
+					mu.Lock()
+					files = append(files, info.Path)
+					mu.Unlock()
+				}
+				var err error
+				if method == "Files" {
+					err = tr.Files(context.Background(),
+						func(info FileInfo, depth int) Action {
+							record(info)
+							return ActionContinue
+						}, ".")
+				} else {
+					err = tr.FilesWithBatch(context.Background(),
+						func(info FileInfo, lctn *LocationBatchInfo, depth int) Action {
+							record(info)
+							return ActionContinue
+						}, ".")
+				}
+				if err != nil {
+					t.Error(err)
+				}
+				sort.Strings(files)
+				if got := strings.Join(files, ","); got != tc.wantFiles {
+					t.Errorf("%s: got files %s; want %s", method, got, tc.wantFiles)
+				}
+				if got := testReadDirs(fsys); got != tc.wantReads {
+					t.Errorf("%s: got reads %s; want %s", method, got, tc.wantReads)
+				}
+			}
+		})
+	}
+}
+
+func TestWithMaxDepth_Batches(t *testing.T) {
+	fsys := testMakeReadDirCounter()
+	var mu sync.Mutex
+	var parents []string
+	err := NewTraverser(WithFS(fsys), WithMinDepth(1), WithMaxDepth(2)).Batches(
+		context.Background(), func(batch Batch, depth int) (Action, map[string]bool) {
+			mu.Lock()
+			parents = append(parents, batch.Parent.Path)
+			mu.Unlock()
+			return ActionContinue, nil
+		}, ".")
+	if err != nil {
+		t.Error(err)
+	}
+	sort.Strings(parents)
+	if got, want := strings.Join(parents, ","), "keep,skip"; got != want {
+		t.Errorf("got batches %s; want %s", got, want)
+	}
+	if got, want := testReadDirs(fsys), ".,keep,skip"; got != want {
+		t.Errorf("got reads %s; want %s", got, want)
+	}
+}
+
+// testReadDirs returns the directories read in fsys, sorted and joined.
+func testReadDirs(fsys *testReadDirCounter) string {
+	var dirs []string
+	for dir := range fsys.counter {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return strings.Join(dirs, ",")
+}
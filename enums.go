@@ -4,6 +4,9 @@ import "strings"
 
 type Action int8
 type FileCategory int8
+type SortOrder int8
+type TraversalOrder int8
+type HardLinkPolicy int8
 
 const (
 	ActionContinue Action = iota + 1
@@ -19,6 +22,24 @@ const (
 	Directory
 )
 
+const (
+	SortByCategory SortOrder = iota + 1
+	SortByName
+	SortNone
+)
+
+const (
+	DepthFirst TraversalOrder = iota + 1
+	BreadthFirst
+	LevelSync
+)
+
+const (
+	KeepHardLinks HardLinkPolicy = iota + 1
+	SkipHardLinks
+	MarkHardLinks
+)
+
 var actionStrings = [...]string{
 	"Unknown",
 	"Continue",
@@ -35,6 +56,27 @@ var fileCategoryStrings = [...]string{
 	"Directory",
 }
 
+var sortOrderStrings = [...]string{
+	"Unknown",
+	"SortByCategory",
+	"SortByName",
+	"SortNone",
+}
+
+var traversalOrderStrings = [...]string{
+	"Unknown",
+	"DepthFirst",
+	"BreadthFirst",
+	"LevelSync",
+}
+
+var hardLinkPolicyStrings = [...]string{
+	"Unknown",
+	"KeepHardLinks",
+	"SkipHardLinks",
+	"MarkHardLinks",
+}
+
 func ParseAction(s string) Action {
 	for i := range actionStrings {
 		if strings.EqualFold(s, actionStrings[i]) {
@@ -84,3 +126,78 @@ func (fc *FileCategory) UnmarshalText(text []byte) error {
 	*fc = ParseFileCategory(string(text))
 	return nil
 }
+
+func ParseSortOrder(s string) SortOrder {
+	for i := range sortOrderStrings {
+		if strings.EqualFold(s, sortOrderStrings[i]) {
+			return SortOrder(i)
+		}
+	}
+	return 0 // Stands for "Unknown".
+}
+
+func (so SortOrder) String() string {
+	if so < SortByCategory || so > SortNone {
+		return sortOrderStrings[0]
+	}
+	return sortOrderStrings[so]
+}
+
+func (so SortOrder) MarshalText() ([]byte, error) {
+	return []byte(so.String()), nil
+}
+
+func (so *SortOrder) UnmarshalText(text []byte) error {
+	*so = ParseSortOrder(string(text))
+	return nil
+}
+
+func ParseTraversalOrder(s string) TraversalOrder {
+	for i := range traversalOrderStrings {
+		if strings.EqualFold(s, traversalOrderStrings[i]) {
+			return TraversalOrder(i)
+		}
+	}
+	return 0 // Stands for "Unknown".
+}
+
+func (to TraversalOrder) String() string {
+	if to < DepthFirst || to > LevelSync {
+		return traversalOrderStrings[0]
+	}
+	return traversalOrderStrings[to]
+}
+
+func (to TraversalOrder) MarshalText() ([]byte, error) {
+	return []byte(to.String()), nil
+}
+
+func (to *TraversalOrder) UnmarshalText(text []byte) error {
+	*to = ParseTraversalOrder(string(text))
+	return nil
+}
+
+func ParseHardLinkPolicy(s string) HardLinkPolicy {
+	for i := range hardLinkPolicyStrings {
+		if strings.EqualFold(s, hardLinkPolicyStrings[i]) {
+			return HardLinkPolicy(i)
+		}
+	}
+	return 0 // Stands for "Unknown".
+}
+
+func (hlp HardLinkPolicy) String() string {
+	if hlp < KeepHardLinks || hlp > MarkHardLinks {
+		return hardLinkPolicyStrings[0]
+	}
+	return hardLinkPolicyStrings[hlp]
+}
+
+func (hlp HardLinkPolicy) MarshalText() ([]byte, error) {
+	return []byte(hlp.String()), nil
+}
+
+func (hlp *HardLinkPolicy) UnmarshalText(text []byte) error {
+	*hlp = ParseHardLinkPolicy(string(text))
+	return nil
+}
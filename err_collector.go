@@ -0,0 +1,52 @@
+package gotfp
+
+import (
+	"errors"
+	"io/fs"
+	"sort"
+	"sync"
+)
+
+// tErrCollector collects errors from all workers of a traversal.
+// The zero value is ready to use, keeping all errors.
+type tErrCollector struct {
+	Max int // Maximum number of errors kept. Non-positive for no limit.
+
+	mu         sync.Mutex
+	errs       []error
+	numDropped int
+}
+
+func (ec *tErrCollector) add(path string, err error) {
+	if err == nil {
+		return
+	}
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	if ec.Max > 0 && len(ec.errs) >= ec.Max {
+		ec.numDropped++
+		return
+	}
+	ec.errs = append(ec.errs, wrapPathError(path, err))
+}
+
+// result returns the collected errors as a *TraversalError,
+// with stopErr as the last one if it is not nil.
+// It returns stopErr itself if no error is collected.
+// Ensure all workers have exited.
+func (ec *tErrCollector) result(stopErr error) error {
+	if len(ec.errs) == 0 && ec.numDropped == 0 {
+		return stopErr
+	}
+	errs := ec.errs
+	sort.SliceStable(errs, func(i, j int) bool {
+		var pe1, pe2 *fs.PathError
+		errors.As(errs[i], &pe1)
+		errors.As(errs[j], &pe2)
+		return pe1.Path < pe2.Path
+	})
+	if stopErr != nil {
+		errs = append(errs, stopErr)
+	}
+	return &TraversalError{Errs: errs, NumDropped: ec.numDropped}
+}
@@ -0,0 +1,71 @@
+package gotfp
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+)
+
+func TestTraverser_Files_TraversalError(t *testing.T) {
+	root := testMakeTree(t, "a/b.txt", "c.txt")
+	missing := filepath.Join(root, "missing")
+	err := NewTraverser().Files(context.Background(),
+		func(info FileInfo, depth int) Action {
+			if info.Path == filepath.Join(root, "c.txt") {
+				return Action(100)
+			}
+			return ActionContinue
+		}, root, missing)
+	var te *TraversalError
+	if !errors.As(err, &te) {
+		t.Fatalf("got error %v; want *TraversalError", err)
+	}
+	if len(te.Errs) != 2 || te.NumDropped != 0 {
+		t.Fatalf("got %d errors, %d dropped; want 2, 0",
+			len(te.Errs), te.NumDropped)
+	}
+	var uae *UnknownActionError
+	if !errors.As(err, &uae) {
+		t.Error("UnknownActionError not found")
+	}
+	if !errors.Is(errors.Join(errors.New("other"), err), fs.ErrNotExist) {
+		t.Error("fs.ErrNotExist not found")
+	}
+	// Sorted by path.
+	var pe *fs.PathError
+	if !errors.As(te.Errs[0], &pe) || pe.Path != filepath.Join(root, "c.txt") {
+		t.Errorf("got first error %v; want error of c.txt", te.Errs[0])
+	}
+	if !errors.As(te.Errs[1], &pe) || pe.Path != missing {
+		t.Errorf("got second error %v; want error of %q", te.Errs[1], missing)
+	}
+}
+
+func TestTraverser_Batches_MaxErrors(t *testing.T) {
+	root := testMakeTree(t, "a.txt")
+	tr := NewTraverser(WithMaxErrors(1))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := tr.Batches(ctx, func(batch Batch, depth int) (
+		Action, map[string]bool) {
+		return ActionContinue, nil
+	}, root)
+	if err != context.Canceled {
+		t.Errorf("got error %v; want %v", err, context.Canceled)
+	}
+	err = tr.Batches(context.Background(), func(batch Batch, depth int) (
+		Action, map[string]bool) {
+		return ActionContinue, nil
+	}, filepath.Join(root, "x"), filepath.Join(root, "y"),
+		filepath.Join(root, "z"))
+	var te *TraversalError
+	if !errors.As(err, &te) {
+		t.Fatalf("got error %v; want *TraversalError", err)
+	}
+	if len(te.Errs) != 1 || te.NumDropped != 2 {
+		t.Errorf("got %d errors, %d dropped; want 1, 2",
+			len(te.Errs), te.NumDropped)
+	}
+}
@@ -3,6 +3,8 @@ package gotfp
 import (
 	"errors"
 	"fmt"
+	"io/fs"
+	"strings"
 )
 
 type UnknownActionError struct {
@@ -13,6 +15,39 @@ type UnknownFileCategoryError struct {
 	fileCategory interface{}
 }
 
+type UnknownSortOrderError struct {
+	sortOrder interface{}
+}
+
+type UnknownTraversalOrderError struct {
+	traversalOrder interface{}
+}
+
+type UnknownHardLinkPolicyError struct {
+	hardLinkPolicy interface{}
+}
+
+// SymlinkCycleError is reported when following symbolic links and
+// a directory is the same as one of its ancestors,
+// which means that the links form a cycle.
+type SymlinkCycleError struct {
+	Path        string // The path to the directory visited again.
+	VisitedPath string // The path to the ancestor that is the same directory.
+}
+
+// TraversalError is returned by the methods of Traverser if any error occurs.
+//
+// It holds every per-path failure, including the error of each ErrorFile
+// (FileInfo.Err) and errors reported by workers such as
+// UnknownActionError, as an *fs.PathError, sorted by path.
+// If the traversal is stopped by its context, ctx.Err() is the last one.
+// It works with errors.Is and errors.As like the errors returned by
+// errors.Join.
+type TraversalError struct {
+	Errs       []error
+	NumDropped int // The number of errors dropped due to WithMaxErrors.
+}
+
 var ErrNoDirToSkip error = errors.New("gotfp: no directory to skip")
 
 func NewUnknownActionError(action interface{}) error {
@@ -69,3 +104,125 @@ func (ufce *UnknownFileCategoryError) Error() string {
 			ufce.fileCategory)
 	}
 }
+
+func NewUnknownSortOrderError(sortOrder interface{}) error {
+	switch sortOrder.(type) {
+	case SortOrder:
+		so := sortOrder.(SortOrder)
+		if so >= SortByCategory && so <= SortNone {
+			panic(fmt.Errorf(
+				"gotfp: sort order %q is known but mark as unknown", so))
+		}
+	case string:
+		// Do nothing.
+	default:
+		panic(fmt.Errorf(
+			"gotfp: type of sortOrder should be SortOrder or string, but got %T",
+			sortOrder))
+	}
+	return &UnknownSortOrderError{sortOrder: sortOrder}
+}
+
+func (usoe *UnknownSortOrderError) Error() string {
+	switch usoe.sortOrder.(type) {
+	case SortOrder:
+		return fmt.Sprintf("gotfp: sort order (%d) is unknown", usoe.sortOrder)
+	default:
+		return fmt.Sprintf("gotfp: sort order (%s) is unknown", usoe.sortOrder)
+	}
+}
+
+func NewUnknownTraversalOrderError(traversalOrder interface{}) error {
+	switch traversalOrder.(type) {
+	case TraversalOrder:
+		to := traversalOrder.(TraversalOrder)
+		if to >= DepthFirst && to <= LevelSync {
+			panic(fmt.Errorf(
+				"gotfp: traversal order %q is known but mark as unknown", to))
+		}
+	case string:
+		// Do nothing.
+	default:
+		panic(fmt.Errorf(
+			"gotfp: type of traversalOrder should be TraversalOrder or string, but got %T",
+			traversalOrder))
+	}
+	return &UnknownTraversalOrderError{traversalOrder: traversalOrder}
+}
+
+func (utoe *UnknownTraversalOrderError) Error() string {
+	switch utoe.traversalOrder.(type) {
+	case TraversalOrder:
+		return fmt.Sprintf("gotfp: traversal order (%d) is unknown",
+			utoe.traversalOrder)
+	default:
+		return fmt.Sprintf("gotfp: traversal order (%s) is unknown",
+			utoe.traversalOrder)
+	}
+}
+
+func NewUnknownHardLinkPolicyError(hardLinkPolicy interface{}) error {
+	switch hardLinkPolicy.(type) {
+	case HardLinkPolicy:
+		hlp := hardLinkPolicy.(HardLinkPolicy)
+		if hlp >= KeepHardLinks && hlp <= MarkHardLinks {
+			panic(fmt.Errorf(
+				"gotfp: hard link policy %q is known but mark as unknown", hlp))
+		}
+	case string:
+		// Do nothing.
+	default:
+		panic(fmt.Errorf(
+			"gotfp: type of hardLinkPolicy should be HardLinkPolicy or string, but got %T",
+			hardLinkPolicy))
+	}
+	return &UnknownHardLinkPolicyError{hardLinkPolicy: hardLinkPolicy}
+}
+
+func (uhlpe *UnknownHardLinkPolicyError) Error() string {
+	switch uhlpe.hardLinkPolicy.(type) {
+	case HardLinkPolicy:
+		return fmt.Sprintf("gotfp: hard link policy (%d) is unknown",
+			uhlpe.hardLinkPolicy)
+	default:
+		return fmt.Sprintf("gotfp: hard link policy (%s) is unknown",
+			uhlpe.hardLinkPolicy)
+	}
+}
+
+func (sce *SymlinkCycleError) Error() string {
+	return fmt.Sprintf(
+		"gotfp: symbolic link cycle detected: %q is the same directory as %q",
+		sce.Path, sce.VisitedPath)
+}
+
+func (te *TraversalError) Error() string {
+	var b strings.Builder
+	n := len(te.Errs) + te.NumDropped
+	if n == 1 {
+		b.WriteString("gotfp: 1 error occurred during traversal")
+	} else {
+		fmt.Fprintf(&b, "gotfp: %d errors occurred during traversal", n)
+	}
+	if te.NumDropped > 0 {
+		fmt.Fprintf(&b, " (%d dropped)", te.NumDropped)
+	}
+	for _, err := range te.Errs {
+		b.WriteByte('\n')
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+func (te *TraversalError) Unwrap() []error {
+	return te.Errs
+}
+
+// wrapPathError returns err as an *fs.PathError with path.
+func wrapPathError(path string, err error) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) && pe.Path == path {
+		return err
+	}
+	return &fs.PathError{Op: "traverse", Path: path, Err: err}
+}
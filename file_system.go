@@ -0,0 +1,229 @@
+package gotfp
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// tFileSystem is the file system that a traversal reads.
+// Paths are in the form of the file system: OS-specific for the OS,
+// and slash-separated unrooted paths for an fs.FS.
+type tFileSystem interface {
+	Lstat(name string) (fs.FileInfo, error)
+	Stat(name string) (fs.FileInfo, error)
+	// ReadDir returns the entries of files in the directory,
+	// sorted by name if sorted is true.
+	ReadDir(name string, sorted bool) ([]fs.DirEntry, error)
+	// OpenDir opens the directory to read its entries chunk by chunk.
+	OpenDir(name string) (tDirReader, error)
+	Open(name string) (io.ReadCloser, error)
+	// EvalSymlinks returns the path name after the evaluation of
+	// any symbolic links.
+	EvalSymlinks(name string) (string, error)
+
+	Join(elem ...string) string
+	Dir(name string) string
+	Base(name string) string
+	// Root returns the path used to traverse from the root.
+	Root(name string) string
+}
+
+type tOSFileSystem struct{}
+
+var osFileSystem tFileSystem = tOSFileSystem{}
+
+func (tOSFileSystem) Lstat(name string) (fs.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (tOSFileSystem) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (tOSFileSystem) ReadDir(name string, sorted bool) (
+	[]fs.DirEntry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // Ignore error.
+	entries, err := f.ReadDir(-1)
+	if err != nil {
+		return nil, err
+	}
+	if sorted && len(entries) > 0 {
+		sortDirEntries(entries)
+	}
+	return entries, nil
+}
+
+func (tOSFileSystem) OpenDir(name string) (tDirReader, error) {
+	return os.Open(name)
+}
+
+func (tOSFileSystem) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (tOSFileSystem) EvalSymlinks(name string) (string, error) {
+	return filepath.EvalSymlinks(name)
+}
+
+func (tOSFileSystem) Join(elem ...string) string {
+	return filepath.Join(elem...)
+}
+
+func (tOSFileSystem) Dir(name string) string {
+	return filepath.Dir(name)
+}
+
+func (tOSFileSystem) Base(name string) string {
+	return filepath.Base(name)
+}
+
+func (tOSFileSystem) Root(name string) string {
+	// Try to get absolute path.
+	root, err := filepath.Abs(name)
+	if err != nil {
+		root = filepath.Clean(name)
+	}
+	return root
+}
+
+// tFSFileSystem adapts an fs.FS.
+// Symbolic links are recognized only if FS implements fs.ReadLinkFS.
+type tFSFileSystem struct {
+	FS fs.FS
+}
+
+type tFSDirReader struct {
+	fs.ReadDirFile
+}
+
+func (fsys tFSFileSystem) Lstat(name string) (fs.FileInfo, error) {
+	if rlfs, ok := fsys.FS.(fs.ReadLinkFS); ok {
+		return rlfs.Lstat(name)
+	}
+	return fs.Stat(fsys.FS, name)
+}
+
+func (fsys tFSFileSystem) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(fsys.FS, name)
+}
+
+func (fsys tFSFileSystem) ReadDir(name string, sorted bool) (
+	[]fs.DirEntry, error) {
+	// fs.ReadDir always sorts the entries, but fs.ReadDirFS may not.
+	entries, err := fs.ReadDir(fsys.FS, name)
+	if err != nil {
+		return nil, err
+	}
+	if sorted && !sort.SliceIsSorted(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	}) {
+		sortDirEntries(entries)
+	}
+	return entries, nil
+}
+
+func (fsys tFSFileSystem) OpenDir(name string) (tDirReader, error) {
+	f, err := fsys.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if rdf, ok := f.(fs.ReadDirFile); ok {
+		// Wrap it in a pointer to make it comparable.
+		return &tFSDirReader{ReadDirFile: rdf}, nil
+	}
+	f.Close() // Ignore error.
+	return nil, &fs.PathError{
+		Op:   "readdir",
+		Path: name,
+		Err:  errors.New("not implemented"),
+	}
+}
+
+func (fsys tFSFileSystem) Open(name string) (io.ReadCloser, error) {
+	return fsys.FS.Open(name)
+}
+
+// maxSymlinks is the maximum number of symbolic links followed
+// in evaluating a path.
+const maxSymlinks = 255
+
+func (fsys tFSFileSystem) EvalSymlinks(name string) (string, error) {
+	rlfs, ok := fsys.FS.(fs.ReadLinkFS)
+	if !ok {
+		// There are no symbolic links.
+		return path.Clean(name), nil
+	}
+	// resolved has no symbolic links, so ".." in rest can be
+	// evaluated lexically on it.
+	var resolved string
+	rest := path.Clean(name)
+	for numLinks := 0; rest != ""; {
+		var elem string
+		elem, rest, _ = strings.Cut(rest, "/")
+		p := path.Join(resolved, elem)
+		info, err := rlfs.Lstat(p)
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			resolved = p
+			continue
+		}
+		numLinks++
+		if numLinks > maxSymlinks {
+			return "", &fs.PathError{
+				Op:   "evalsymlinks",
+				Path: name,
+				Err:  errors.New("too many links"),
+			}
+		}
+		target, err := rlfs.ReadLink(p)
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(target) {
+			return "", &fs.PathError{
+				Op:   "evalsymlinks",
+				Path: name,
+				Err:  errors.New("rooted link target"),
+			}
+		}
+		if rest != "" {
+			target += "/" + rest
+		}
+		rest = path.Clean(target)
+	}
+	return resolved, nil
+}
+
+func (tFSFileSystem) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+func (tFSFileSystem) Dir(name string) string {
+	return path.Dir(name)
+}
+
+func (tFSFileSystem) Base(name string) string {
+	return path.Base(name)
+}
+
+func (tFSFileSystem) Root(name string) string {
+	return path.Clean(name)
+}
+
+func sortDirEntries(entries []fs.DirEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+}
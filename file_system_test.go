@@ -0,0 +1,201 @@
+package gotfp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func testMakeMapFS() fstest.MapFS {
+	return fstest.MapFS{
+		"a/b/c.txt":    {Data: []byte("c")},
+		"a/d.txt":      {Data: []byte("d")},
+		"a/link":       {Data: []byte("b"), Mode: fs.ModeSymlink},
+		"e/.gitignore": {Data: []byte("*.log\n")},
+		"e/f.log":      {Data: []byte("f")},
+		"e/g.txt":      {Data: []byte("g")},
+		"h.txt":        {Data: []byte("h")},
+	}
+}
+
+func TestTraverseFilesFS(t *testing.T) {
+	var mu sync.Mutex
+	got := make(map[string]FileCategory)
+	err := TraverseFilesFS(context.Background(), testMakeMapFS(),
+		func(info FileInfo, depth int) Action {
+			mu.Lock()
+			got[info.Path] = info.Cat
+			mu.Unlock()
+			return ActionContinue
+		}, ".")
+	if err != nil {
+		t.Error(err)
+	}
+	want := map[string]FileCategory{
+		".":            Directory,
+		"a":            Directory,
+		"a/b":          Directory,
+		"a/b/c.txt":    RegularFile,
+		"a/d.txt":      RegularFile,
+		"a/link":       Symlink,
+		"e":            Directory,
+		"e/.gitignore": RegularFile,
+		"e/f.log":      RegularFile,
+		"e/g.txt":      RegularFile,
+		"h.txt":        RegularFile,
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%q: got %v; want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestTraverser_FS_Options(t *testing.T) {
+	tr := NewTraverser(WithFS(testMakeMapFS()), WithGitignore(true),
+		WithGitExcludesFile(""), WithExclude("b/"),
+		WithFollowSymlinks(true))
+	var mu sync.Mutex
+	var got []string
+	err := tr.Batches(context.Background(),
+		func(batch Batch, depth int) (Action, map[string]bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, batch.Parent.Path)
+			for _, fis := range [][]FileInfo{batch.RegFiles, batch.Dirs} {
+				for _, fi := range fis {
+					got = append(got, fi.Path)
+				}
+			}
+			return ActionContinue, nil
+		}, "a", "e")
+	if err != nil {
+		t.Error(err)
+	}
+	sort.Strings(got)
+	// a/link is a link to a/b, which is excluded by its own path "b" only.
+	want := []string{"a", "a/d.txt", "a/link", "a/link", "a/link/c.txt",
+		"e", "e/.gitignore", "e/g.txt"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestTraverser_FS_FollowSymlinks_Cycle(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/b.txt": {Data: []byte("b")},
+		"a/up":    {Data: []byte(".."), Mode: fs.ModeSymlink},
+		"a/c":     {Data: []byte("../d"), Mode: fs.ModeSymlink},
+		"d/e.txt": {Data: []byte("e")},
+	}
+	var mu sync.Mutex
+	got := make(map[string]FileCategory)
+	errs := make(map[string]error)
+	err := NewTraverser(WithFS(fsys), WithFollowSymlinks(true)).Files(
+		context.Background(), func(info FileInfo, depth int) Action {
+			mu.Lock()
+			defer mu.Unlock()
+			got[info.Path], errs[info.Path] = info.Cat, info.Err
+			return ActionContinue
+		}, ".")
+	var sce *SymlinkCycleError
+	if !errors.As(err, &sce) {
+		t.Errorf("got error %v; want *SymlinkCycleError", err)
+	}
+	want := map[string]FileCategory{
+		".":         Directory,
+		"a":         Directory,
+		"a/b.txt":   RegularFile,
+		"a/c":       Directory,
+		"a/c/e.txt": RegularFile,
+		"a/up":      ErrorFile,
+		"d":         Directory,
+		"d/e.txt":   RegularFile,
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%q: got %v; want %v", k, got[k], v)
+		}
+	}
+	if !errors.As(errs["a/up"], &sce) {
+		t.Errorf("a/up: got error %v; want *SymlinkCycleError", errs["a/up"])
+	} else if sce.VisitedPath != "." {
+		t.Errorf("got visited path %q; want %q", sce.VisitedPath, ".")
+	}
+}
+
+func TestFSFileSystem_EvalSymlinks(t *testing.T) {
+	fsys := tFSFileSystem{FS: fstest.MapFS{
+		"a/b/c.txt": {Data: []byte("c")},
+		"a/link":    {Data: []byte("b"), Mode: fs.ModeSymlink},
+		"a/up":      {Data: []byte("../a/./link/"), Mode: fs.ModeSymlink},
+		"loop":      {Data: []byte("loop"), Mode: fs.ModeSymlink},
+		"rooted":    {Data: []byte("/a"), Mode: fs.ModeSymlink},
+	}}
+	testCases := []struct {
+		name string
+		want string // Empty for an error.
+	}{
+		{".", "."},
+		{"a/b/c.txt", "a/b/c.txt"},
+		{"a/link", "a/b"},
+		{"a/link/c.txt", "a/b/c.txt"},
+		{"a/up/c.txt", "a/b/c.txt"},
+		{"a/missing", ""},
+		{"loop", ""},
+		{"rooted", ""},
+	}
+	for _, tc := range testCases {
+		got, err := fsys.EvalSymlinks(tc.name)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("%q: got %q; want an error", tc.name, got)
+			}
+		} else if err != nil || got != tc.want {
+			t.Errorf("%q: got %q, %v; want %q", tc.name, got, err, tc.want)
+		}
+	}
+}
+
+func TestTraverser_Open(t *testing.T) {
+	root := testMakeTree(t, "a.txt")
+	for _, tc := range []struct {
+		tr   *Traverser
+		path string
+		want string
+	}{
+		{NewTraverser(), filepath.Join(root, "a.txt"), "a.txt"},
+		{NewTraverser(WithFS(testMakeMapFS())), "a/b/c.txt", "c"},
+	} {
+		r, err := tc.tr.Open(tc.path)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		data, err := io.ReadAll(r)
+		r.Close() // Ignore error.
+		if err != nil {
+			t.Error(err)
+		} else if string(data) != tc.want {
+			t.Errorf("%q: got %q; want %q", tc.path, data, tc.want)
+		}
+	}
+	// The file system of the OS is not used with WithFS.
+	if _, err := NewTraverser(WithFS(testMakeMapFS())).Open(
+		filepath.Join(root, "a.txt")); err == nil {
+		t.Error("opened a file of the OS in the file system of WithFS")
+	}
+}
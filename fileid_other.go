@@ -0,0 +1,35 @@
+//go:build !unix
+
+package gotfp
+
+import "os"
+
+// tFileID identifies a file on the system.
+// Device and inode numbers are unavailable on this platform,
+// so the path with all symbolic links resolved is used instead.
+type tFileID struct {
+	Path string
+}
+
+// getFileID returns the path of the file with all symbolic links resolved
+// through fsys.
+func getFileID(fsys tFileSystem, path string, info os.FileInfo) (
+	id tFileID, ok bool) {
+	p, err := fsys.EvalSymlinks(path)
+	if err != nil {
+		return
+	}
+	return tFileID{Path: p}, true
+}
+
+// getDeviceID always reports false,
+// as device numbers are unavailable on this platform.
+func getDeviceID(info os.FileInfo) (dev uint64, ok bool) {
+	return
+}
+
+// getLinkCount always reports false,
+// as link counts are unavailable on this platform.
+func getLinkCount(info os.FileInfo) (n uint64, ok bool) {
+	return
+}
@@ -0,0 +1,41 @@
+package gotfp
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+const fileIDSetShardNum = 64
+
+// tFileIDSet is a concurrency-safe set of files,
+// which records the first path seen for each file.
+// It is sharded to reduce lock contention between workers.
+// The zero value is ready to use.
+type tFileIDSet struct {
+	seed   maphash.Seed
+	once   sync.Once
+	shards [fileIDSetShardNum]struct {
+		sync.Mutex
+		m map[tFileID]string
+	}
+}
+
+// LoadOrStore returns the first path recorded for id and true if present.
+// Otherwise, it records path for id and returns path and false.
+func (s *tFileIDSet) LoadOrStore(id tFileID, path string) (
+	first string, loaded bool) {
+	s.once.Do(func() {
+		s.seed = maphash.MakeSeed()
+	})
+	shard := &s.shards[maphash.Comparable(s.seed, id)%fileIDSetShardNum]
+	shard.Lock()
+	defer shard.Unlock()
+	if first, loaded = shard.m[id]; loaded {
+		return
+	}
+	if shard.m == nil {
+		shard.m = make(map[tFileID]string)
+	}
+	shard.m[id] = path
+	return path, false
+}
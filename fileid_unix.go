@@ -0,0 +1,59 @@
+//go:build unix
+
+package gotfp
+
+import (
+	"os"
+	"syscall"
+)
+
+// tFileID identifies a file on the system, by its device and
+// inode numbers, or by its path with all symbolic links resolved
+// if these numbers are unavailable (e.g., on an fs.FS).
+type tFileID struct {
+	Dev  uint64
+	Ino  uint64
+	Path string
+}
+
+// getFileID returns the device and inode numbers of the file if available
+// in info. Otherwise, it returns the path of the file with all symbolic
+// links resolved through fsys.
+func getFileID(fsys tFileSystem, path string, info os.FileInfo) (
+	id tFileID, ok bool) {
+	if info == nil {
+		return
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && st != nil {
+		return tFileID{Dev: uint64(st.Dev), Ino: uint64(st.Ino)}, true
+	}
+	p, err := fsys.EvalSymlinks(path)
+	if err != nil {
+		return
+	}
+	return tFileID{Path: p}, true
+}
+
+// getDeviceID returns the device number of the file.
+func getDeviceID(info os.FileInfo) (dev uint64, ok bool) {
+	if info == nil {
+		return
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st == nil {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
+
+// getLinkCount returns the number of hard links to the file.
+func getLinkCount(info os.FileInfo) (n uint64, ok bool) {
+	if info == nil {
+		return
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st == nil {
+		return 0, false
+	}
+	return uint64(st.Nlink), true
+}
@@ -0,0 +1,82 @@
+package gotfp
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+type tGlob struct {
+	Elems   []string
+	DirOnly bool
+}
+
+// Include and exclude patterns, matched against the slash-separated path
+// relative to the root.
+type tFilter struct {
+	Includes []tGlob
+	Excludes []tGlob
+}
+
+// It panics if the pattern is malformed.
+func newGlob(pattern string) tGlob {
+	if err := validateGlob(strings.TrimSuffix(pattern, "/")); err != nil {
+		panic(err)
+	}
+	return tGlob{
+		Elems:   strings.Split(strings.TrimSuffix(pattern, "/"), "/"),
+		DirOnly: strings.HasSuffix(pattern, "/"),
+	}
+}
+
+func (g tGlob) match(rel []string, isDir bool) bool {
+	if g.DirOnly && !isDir {
+		return false
+	}
+	return matchGlobElems(g.Elems, rel)
+}
+
+// excludes reports whether the file should be neither reported
+// nor descended into.
+// Roots are never excluded.
+func (f *tFilter) excludes(root, path string, isDir bool) bool {
+	if f == nil || len(f.Excludes) == 0 || path == root {
+		return false
+	}
+	rel := relElems(root, path)
+	for _, g := range f.Excludes {
+		if g.match(rel, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// includes reports whether the file should be reported.
+// If there is no include pattern, all files are included.
+// Roots are always included.
+func (f *tFilter) includes(root, path string, isDir bool) bool {
+	if f == nil || len(f.Includes) == 0 || path == root {
+		return true
+	}
+	rel := relElems(root, path)
+	for _, g := range f.Includes {
+		if g.match(rel, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ensure path is root or under root.
+func relElems(root, path string) []string {
+	if path == root {
+		return []string{"."}
+	}
+	root, path = filepath.ToSlash(root), filepath.ToSlash(path)
+	if root != "." {
+		// The root of an fs.FS is ".", and the paths under it
+		// do not start with "./".
+		path = strings.TrimPrefix(path, strings.TrimSuffix(root, "/")+"/")
+	}
+	return strings.Split(path, "/")
+}
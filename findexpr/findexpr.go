@@ -0,0 +1,422 @@
+// Package findexpr compiles expressions in the syntax of find(1)
+// into handlers of gotfp.
+//
+// An expression is a list of arguments, as passed to find after its roots,
+// e.g., []string{"-name", "*.go", "-o", "-type", "d", "-empty"}.
+// The supported primaries are:
+//
+//	-name pattern   base name matches the shell pattern
+//	-iname pattern  like -name, but case-insensitive
+//	-path pattern   path (see Expr.DisplayPath) matches the shell pattern,
+//	                where '*' matches '/'
+//	-ipath pattern  like -path, but case-insensitive
+//	-type c         type is c: f, d, l, p, s, c, or b, or a list like "f,l"
+//	-size [+-]n[c|w|b|k|M|G]
+//	                size rounded up to the unit (default b, 512 bytes)
+//	                is more than (+), less than (-), or exactly n units
+//	-mtime [+-]n    modified n days ago (rounded down)
+//	-mmin [+-]n     modified n minutes ago (rounded down)
+//	-newer file     modified more recently than file
+//	-perm [-/]mode  permission bits (octal) are exactly mode,
+//	                include all of mode (-), or any of mode (/)
+//	-empty          empty regular file or directory (see Expr.Traverser)
+//	-true, -false   always true, or false
+//	-prune          true; do not descend into the directory
+//	-print          true; call the handler for the file
+//
+// They are combined by the operators, in decreasing precedence:
+//
+//	( expr )        parentheses
+//	! expr          also -not
+//	expr expr       also expr -a expr and expr -and expr
+//	expr -o expr    also expr -or expr
+//
+// As find, if the expression contains no -print, it is taken as
+// "( expr ) -print", and an empty expression matches all files.
+package findexpr
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/donyori/gotfp"
+)
+
+// SyntaxError is reported when an expression is malformed.
+type SyntaxError struct {
+	// Pos is the index of the malformed argument,
+	// or the number of arguments if the expression ends too early.
+	Pos int
+	Arg string // The malformed argument, empty if the expression ends too early.
+	Msg string
+}
+
+func (se *SyntaxError) Error() string {
+	if se.Arg == "" {
+		return fmt.Sprintf("findexpr: argument %d: %s", se.Pos+1, se.Msg)
+	}
+	return fmt.Sprintf("findexpr: argument %d %q: %s", se.Pos+1, se.Arg, se.Msg)
+}
+
+// Expr is a compiled expression.
+type Expr struct {
+	// Now is the time that -mtime and -mmin are relative to.
+	// Parse sets it to the current time.
+	Now time.Time
+	// DisplayPath maps the path of a file (FileInfo.Path) to the path
+	// matched by -path and -ipath, which should be as find prints it,
+	// i.e., under the root as given, e.g., "./vendor" for "vendor"
+	// in the root ".", so that "-path ./vendor -prune" works as in find.
+	// If it is nil, as Parse sets it, FileInfo.Path is matched, which is
+	// absolute for the file system of the OS, as Traverser makes roots
+	// absolute.
+	DisplayPath func(path string) string
+	// Traverser opens directories for -empty, so that they are read from
+	// the file system that it traverses (see gotfp.WithFS).
+	// If it is nil, as Parse sets it, the file system of the OS is read.
+	Traverser *gotfp.Traverser
+
+	root     tNode // Nil for an empty expression.
+	hasPrint bool
+}
+
+// tEvalCtx is the context of evaluating an expression for a file.
+type tEvalCtx struct {
+	Expr    *Expr
+	Info    gotfp.FileInfo
+	Depth   int
+	Handler gotfp.FileHandler // Nil if only matching.
+
+	isPruned bool
+	action   gotfp.Action // Action returned by Handler, if called.
+}
+
+// tNode is a node of the syntax tree.
+type tNode interface {
+	eval(c *tEvalCtx) bool
+	String() string
+}
+
+// Parse compiles the expression args.
+// It returns a *SyntaxError if args is malformed,
+// or if the file of -newer cannot be stat.
+func Parse(args ...string) (*Expr, error) {
+	p := &tParser{args: args}
+	e := &Expr{Now: time.Now()}
+	p.expr = e
+	if len(args) == 0 {
+		return e, nil
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(args) {
+		// Only an unmatched ')' can stop parseOr early.
+		return nil, p.errorf(p.pos, "unmatched ')'")
+	}
+	e.root = root
+	return e, nil
+}
+
+// String returns e with all operators and parentheses explicit,
+// e.g., "(-name *.go -a -type f)".
+func (e *Expr) String() string {
+	if e.root == nil {
+		return "-true"
+	}
+	return e.root.String()
+}
+
+// Match reports whether info matches e, with -print taken as -true.
+func (e *Expr) Match(info gotfp.FileInfo, depth int) bool {
+	c := &tEvalCtx{Expr: e, Info: info, Depth: depth}
+	return e.root == nil || e.root.eval(c)
+}
+
+// Handler returns a gotfp.FileHandler calling handler for each file
+// printed by e, i.e., matching e if e has no -print.
+//
+// It returns the action returned by handler, or ActionContinue if handler
+// is not called, except that it returns ActionSkip for a directory
+// that -prune is evaluated for, unless handler returns ActionExit.
+// Once handler returns ActionExit, it is no more called for the file.
+//
+// It panics if handler is nil.
+func (e *Expr) Handler(handler gotfp.FileHandler) gotfp.FileHandler {
+	if handler == nil {
+		panic("findexpr: handler is nil")
+	}
+	return func(info gotfp.FileInfo, depth int) gotfp.Action {
+		c := &tEvalCtx{
+			Expr:    e,
+			Info:    info,
+			Depth:   depth,
+			Handler: handler,
+			action:  gotfp.ActionContinue,
+		}
+		isMatched := e.root == nil || e.root.eval(c)
+		if isMatched && !e.hasPrint {
+			c.print()
+		}
+		switch {
+		case c.action == gotfp.ActionExit:
+			return gotfp.ActionExit
+		case c.isPruned && info.Cat == gotfp.Directory:
+			return gotfp.ActionSkip
+		}
+		return c.action
+	}
+}
+
+// print calls the handler for the file, if any and not exited.
+func (c *tEvalCtx) print() {
+	if c.Handler == nil || c.action == gotfp.ActionExit {
+		return
+	}
+	action := c.Handler(c.Info, c.Depth)
+	if action == gotfp.ActionExit || c.action == gotfp.ActionContinue {
+		c.action = action
+	}
+}
+
+// displayPath returns the path of the file matched by -path and -ipath.
+func (c *tEvalCtx) displayPath() string {
+	if c.Expr.DisplayPath == nil {
+		return c.Info.Path
+	}
+	return c.Expr.DisplayPath(c.Info.Path)
+}
+
+// open opens the file through c.Expr.Traverser, if any.
+func (c *tEvalCtx) open() (io.ReadCloser, error) {
+	if c.Expr.Traverser == nil {
+		return os.Open(c.Info.Path)
+	}
+	return c.Expr.Traverser.Open(c.Info.Path)
+}
+
+// loadInfo returns the os.FileInfo of the file, or nil if unavailable.
+func (c *tEvalCtx) loadInfo() os.FileInfo {
+	if c.Info.Cat == gotfp.ErrorFile {
+		return nil
+	}
+	fi, err := c.Info.LoadInfo()
+	if err != nil {
+		return nil
+	}
+	return fi
+}
+
+type tAnd struct{ X, Y tNode }
+type tOr struct{ X, Y tNode }
+type tNot struct{ X tNode }
+
+// tPrimary is a primary with its arguments.
+type tPrimary struct {
+	Args []string // The primary and its arguments, as in the expression.
+	Eval func(c *tEvalCtx) bool
+}
+
+func (n *tAnd) eval(c *tEvalCtx) bool { return n.X.eval(c) && n.Y.eval(c) }
+func (n *tOr) eval(c *tEvalCtx) bool  { return n.X.eval(c) || n.Y.eval(c) }
+func (n *tNot) eval(c *tEvalCtx) bool { return !n.X.eval(c) }
+
+func (n *tPrimary) eval(c *tEvalCtx) bool { return n.Eval(c) }
+
+func (n *tAnd) String() string { return "(" + n.X.String() + " -a " + n.Y.String() + ")" }
+func (n *tOr) String() string  { return "(" + n.X.String() + " -o " + n.Y.String() + ")" }
+func (n *tNot) String() string { return "! " + n.X.String() }
+
+func (n *tPrimary) String() string { return strings.Join(n.Args, " ") }
+
+// tParser parses an expression by recursive descent.
+type tParser struct {
+	expr *Expr
+	args []string
+	pos  int
+}
+
+func (p *tParser) errorf(pos int, format string, a ...interface{}) error {
+	se := &SyntaxError{Pos: pos, Msg: fmt.Sprintf(format, a...)}
+	if pos < len(p.args) {
+		se.Arg = p.args[pos]
+	}
+	return se
+}
+
+// peek returns the next argument, or "" at the end.
+func (p *tParser) peek() (arg string, ok bool) {
+	if p.pos >= len(p.args) {
+		return "", false
+	}
+	return p.args[p.pos], true
+}
+
+func (p *tParser) parseOr() (tNode, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		arg, ok := p.peek()
+		if !ok || (arg != "-o" && arg != "-or") {
+			return x, nil
+		}
+		p.pos++
+		if err = p.expectOperand(arg); err != nil {
+			return nil, err
+		}
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		x = &tOr{X: x, Y: y}
+	}
+}
+
+func (p *tParser) parseAnd() (tNode, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		arg, ok := p.peek()
+		if !ok || arg == ")" || arg == "-o" || arg == "-or" {
+			return x, nil
+		}
+		if arg == "-a" || arg == "-and" {
+			p.pos++
+			if err = p.expectOperand(arg); err != nil {
+				return nil, err
+			}
+		}
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = &tAnd{X: x, Y: y}
+	}
+}
+
+// expectOperand reports an error if no operand follows the operator op.
+func (p *tParser) expectOperand(op string) error {
+	arg, ok := p.peek()
+	switch {
+	case !ok:
+		return p.errorf(p.pos, "expected an expression after %s", op)
+	case arg == ")" || arg == "-o" || arg == "-or" || arg == "-a" || arg == "-and":
+		return p.errorf(p.pos, "expected an expression after %s", op)
+	}
+	return nil
+}
+
+func (p *tParser) parseUnary() (tNode, error) {
+	arg, ok := p.peek()
+	if !ok {
+		return nil, p.errorf(p.pos, "expected an expression")
+	}
+	switch arg {
+	case "!", "-not":
+		p.pos++
+		if err := p.expectOperand(arg); err != nil {
+			return nil, err
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &tNot{X: x}, nil
+	case "(":
+		open := p.pos
+		p.pos++
+		if arg, ok = p.peek(); ok && arg == ")" {
+			return nil, p.errorf(open, "empty parentheses")
+		}
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if arg, ok = p.peek(); !ok || arg != ")" {
+			return nil, p.errorf(open, "unmatched '('")
+		}
+		p.pos++
+		return x, nil
+	case ")":
+		return nil, p.errorf(p.pos, "unmatched ')'")
+	case "-o", "-or", "-a", "-and":
+		return nil, p.errorf(p.pos, "expected an expression before %s", arg)
+	}
+	return p.parsePrimary()
+}
+
+func (p *tParser) parsePrimary() (tNode, error) {
+	start := p.pos
+	name := p.args[start]
+	def, ok := primaries[name]
+	if !ok {
+		if !strings.HasPrefix(name, "-") {
+			return nil, p.errorf(start,
+				"paths must precede the expression, or a primary is missing")
+		}
+		if suggestion := suggest(name); suggestion != "" {
+			return nil, p.errorf(start, "unknown primary; did you mean %s?",
+				suggestion)
+		}
+		return nil, p.errorf(start, "unknown primary")
+	}
+	if start+def.NumArgs >= len(p.args) {
+		return nil, p.errorf(len(p.args), "missing argument to %s", name)
+	}
+	p.pos += 1 + def.NumArgs
+	n := &tPrimary{Args: p.args[start:p.pos]}
+	var err error
+	if n.Eval, err = def.Compile(p, p.args[start+1:p.pos]); err != nil {
+		return nil, p.errorf(start+1, "%s: %v", name, err)
+	}
+	return n, nil
+}
+
+// suggest returns the known primary closest to name,
+// or "" if none is close enough.
+func suggest(name string) string {
+	best, bestDist := "", 3
+	for known := range primaries {
+		if d := editDistance(name, known); d < bestDist ||
+			d == bestDist && d < 3 && known < best {
+			best, bestDist = known, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cur := row[j]
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			row[j] = prev + cost
+			if d := row[j-1] + 1; d < row[j] {
+				row[j] = d
+			}
+			if d := cur + 1; d < row[j] {
+				row[j] = d
+			}
+			prev = cur
+		}
+	}
+	return row[len(b)]
+}
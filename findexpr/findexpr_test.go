@@ -0,0 +1,306 @@
+package findexpr
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/donyori/gotfp"
+	"github.com/donyori/gotfp/internal/testfs"
+)
+
+func TestParse_String(t *testing.T) {
+	testCases := []struct {
+		args []string
+		want string
+	}{
+		{nil, "-true"},
+		{[]string{"-name", "*.go"}, "-name *.go"},
+		{[]string{"-name", "*.go", "-type", "f"}, "(-name *.go -a -type f)"},
+		{[]string{"-name", "a", "-o", "-name", "b", "-a", "-type", "f"},
+			"(-name a -o (-name b -a -type f))"},
+		{[]string{"(", "-name", "a", "-or", "-name", "b", ")", "-and", "-not", "-empty"},
+			"((-name a -o -name b) -a ! -empty)"},
+		{[]string{"!", "!", "-true", "-o", "-false", "-false"},
+			"(! ! -true -o (-false -a -false))"},
+		{[]string{"-path", "./x", "-prune", "-o", "-print"},
+			"((-path ./x -a -prune) -o -print)"},
+	}
+	for _, tc := range testCases {
+		e, err := Parse(tc.args...)
+		if err != nil {
+			t.Errorf("%q: %v", tc.args, err)
+			continue
+		}
+		if got := e.String(); got != tc.want {
+			t.Errorf("%q: got %s; want %s", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestParse_SyntaxError(t *testing.T) {
+	testCases := []struct {
+		args []string
+		pos  int
+		want string // Substring of the error message.
+	}{
+		{[]string{"-name"}, 1, "missing argument to -name"},
+		{[]string{"-nmae", "x"}, 0, "did you mean -name?"},
+		{[]string{"-bogus"}, 0, "unknown primary"},
+		{[]string{"dir", "-name", "x"}, 0, "paths must precede"},
+		{[]string{"(", "-true"}, 0, "unmatched '('"},
+		{[]string{"-true", ")"}, 1, "unmatched ')'"},
+		{[]string{"(", ")"}, 0, "empty parentheses"},
+		{[]string{"-true", "-o"}, 2, "expected an expression after -o"},
+		{[]string{"-o", "-true"}, 0, "expected an expression before -o"},
+		{[]string{"-true", "-a", "-o", "-true"}, 2, "expected an expression after -a"},
+		{[]string{"!"}, 1, "expected an expression after !"},
+		{[]string{"-type", "x"}, 1, `unknown type "x"`},
+		{[]string{"-size", "+1X"}, 1, "invalid size"},
+		{[]string{"-mtime", "1.5"}, 1, "invalid number"},
+		{[]string{"-perm", "u+x"}, 1, "symbolic modes are not supported"},
+		{[]string{"-perm", "-8"}, 1, "invalid mode"},
+		{[]string{"-name", "[a"}, 1, "unterminated character class"},
+		{[]string{"-newer", filepath.Join(t.TempDir(), "none")}, 1, "-newer"},
+	}
+	for _, tc := range testCases {
+		_, err := Parse(tc.args...)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("%q: got %v; want *SyntaxError", tc.args, err)
+			continue
+		}
+		if se.Pos != tc.pos {
+			t.Errorf("%q: got position %d; want %d", tc.args, se.Pos, tc.pos)
+		}
+		if !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%q: got %q; want it to contain %q", tc.args, err, tc.want)
+		}
+	}
+}
+
+func TestCompileGlob(t *testing.T) {
+	testCases := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.go", "a.go", true},
+		{"*.go", "dir/a.go", true},
+		{"*.go", "a.goo", false},
+		{"?.txt", "a.txt", true},
+		{"?.txt", "ab.txt", false},
+		{"[ab]*", "b1", true},
+		{"[!ab]*", "b1", false},
+		{"[^ab]*", "c1", true},
+		{"[]]", "]", true},
+		{`\*`, "*", true},
+		{`\*`, "a", false},
+		{"a.b", "axb", false},
+		{"(x)", "(x)", true},
+	}
+	for _, tc := range testCases {
+		re, err := compileGlob(tc.pattern, false)
+		if err != nil {
+			t.Errorf("%q: %v", tc.pattern, err)
+			continue
+		}
+		if got := re.MatchString(tc.name); got != tc.want {
+			t.Errorf("%q, %q: got %t; want %t", tc.pattern, tc.name, got, tc.want)
+		}
+	}
+}
+
+// testFind traverses root with the handler of the expression args,
+// and returns the slash-separated paths relative to root passed to
+// the handler, sorted.
+func testFind(tb testing.TB, root string, args ...string) []string {
+	tb.Helper()
+	e, err := Parse(args...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return testFindExpr(tb, root, e)
+}
+
+// testFindExpr is like testFind, but with the compiled expression e.
+func testFindExpr(tb testing.TB, root string, e *Expr) []string {
+	tb.Helper()
+	var mu sync.Mutex
+	var paths []string
+	err := gotfp.NewTraverser().Files(context.Background(),
+		e.Handler(func(info gotfp.FileInfo, depth int) gotfp.Action {
+			rel, err := filepath.Rel(root, info.Path)
+			if err != nil {
+				tb.Error(err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			paths = append(paths, filepath.ToSlash(rel))
+			return gotfp.ActionContinue
+		}), root)
+	if err != nil {
+		tb.Error(err)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// testFiles is the tree for the tests of Expr handlers.
+var testFiles = map[string]string{
+	"a.go":           "package a",
+	"B.GO":           "",
+	"doc/readme.txt": strings.Repeat("x", 1500),
+	"doc/big.bin":    strings.Repeat("x", 3<<20),
+	"vendor/v.go":    "package v",
+	"old.txt":        "old",
+	"empty/":         "",
+}
+
+func TestExpr_Handler(t *testing.T) {
+	root := testfs.MakeTree(t, testFiles)
+	if err := os.Chmod(filepath.Join(root, "a.go"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-10 * 24 * time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "old.txt"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		args []string
+		want string
+	}{
+		{nil, ".,B.GO,a.go,doc,doc/big.bin,doc/readme.txt,empty,old.txt,vendor,vendor/v.go"},
+		{[]string{"-name", "*.go"}, "a.go,vendor/v.go"},
+		{[]string{"-iname", "*.go"}, "B.GO,a.go,vendor/v.go"},
+		{[]string{"-path", "*/doc/*"}, "doc/big.bin,doc/readme.txt"},
+		{[]string{"-type", "d"}, ".,doc,empty,vendor"},
+		{[]string{"-type", "f", "!", "-name", "*.go"}, "B.GO,doc/big.bin,doc/readme.txt,old.txt"},
+		{[]string{"-name", "vendor", "-prune", "-o", "-name", "*.go", "-print"}, "a.go"},
+		{[]string{"-name", "vendor", "-prune", "-o", "-name", "*.go"}, "a.go,vendor"},
+		{[]string{"-size", "+1M"}, "doc/big.bin"},
+		{[]string{"-size", "-1M", "-type", "f"}, "B.GO"},
+		{[]string{"-size", "3", "-type", "f"}, "doc/readme.txt"},
+		{[]string{"-size", "1500c"}, "doc/readme.txt"},
+		{[]string{"-size", "-3k", "-size", "+1k"}, "doc/readme.txt"},
+		{[]string{"-mtime", "+7"}, "old.txt"},
+		{[]string{"-mtime", "-1", "-type", "f", "-name", "*.txt"}, "doc/readme.txt"},
+		{[]string{"-mmin", "+60"}, "old.txt"},
+		{[]string{"-newer", filepath.Join(root, "old.txt"), "-name", "*.txt"}, "doc/readme.txt"},
+		{[]string{"-perm", "755", "-type", "f"}, "a.go"},
+		{[]string{"-perm", "-100", "-type", "f"}, "a.go"},
+		{[]string{"-perm", "/111", "!", "-type", "d"}, "a.go"},
+		{[]string{"-empty"}, "B.GO,empty"},
+		{[]string{"-false", "-print", "-o", "-name", "a.go", "-print", "-print"}, "a.go,a.go"},
+	}
+	for _, tc := range testCases {
+		got := strings.Join(testFind(t, root, tc.args...), ",")
+		if got != tc.want {
+			t.Errorf("%q: got %s; want %s", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestExpr_DisplayPath(t *testing.T) {
+	root := testfs.MakeTree(t, testFiles)
+	// As find prints the paths in the root ".".
+	display := func(path string) string {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			t.Error(err)
+		}
+		if rel == "." {
+			return rel
+		}
+		return "." + string(filepath.Separator) + rel
+	}
+	testCases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-path", "./vendor", "-prune", "-o", "-print"},
+			".,B.GO,a.go,doc,doc/big.bin,doc/readme.txt,empty,old.txt"},
+		{[]string{"-path", "./doc/*"}, "doc/big.bin,doc/readme.txt"},
+		{[]string{"-ipath", "./B.go"}, "B.GO"},
+		{[]string{"-path", "."}, "."},
+	}
+	for _, tc := range testCases {
+		e, err := Parse(tc.args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.DisplayPath = display
+		got := strings.Join(testFindExpr(t, root, e), ",")
+		if got != tc.want {
+			t.Errorf("%q: got %s; want %s", tc.args, got, tc.want)
+		}
+	}
+	// Without DisplayPath, the absolute path is matched.
+	if got := strings.Join(testFind(t, root, "-path", "./vendor"), ","); got != "" {
+		t.Errorf("got %s; want none", got)
+	}
+}
+
+func TestExpr_Traverser(t *testing.T) {
+	fsys := fstest.MapFS{
+		"empty":      {Mode: fs.ModeDir},
+		"full/a.txt": {Data: []byte("a")},
+		"zero.txt":   {},
+	}
+	e, err := Parse("-empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := gotfp.NewTraverser(gotfp.WithFS(fsys))
+	e.Traverser = tr
+	var mu sync.Mutex
+	var paths []string
+	err = tr.Files(context.Background(),
+		e.Handler(func(info gotfp.FileInfo, depth int) gotfp.Action {
+			mu.Lock()
+			defer mu.Unlock()
+			paths = append(paths, info.Path)
+			return gotfp.ActionContinue
+		}), ".")
+	if err != nil {
+		t.Error(err)
+	}
+	sort.Strings(paths)
+	if got, want := strings.Join(paths, ","), "empty,zero.txt"; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+}
+
+func TestExpr_Handler_Action(t *testing.T) {
+	root := testfs.MakeTree(t, map[string]string{"a.go": "package a", "doc/": ""})
+	e, err := Parse("-name", "doc", "-prune")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := e.Handler(func(info gotfp.FileInfo, depth int) gotfp.Action {
+		return gotfp.ActionExit
+	})
+	doc := gotfp.FileInfo{Path: filepath.Join(root, "doc"), Cat: gotfp.Directory}
+	if got := h(doc, 1); got != gotfp.ActionExit {
+		t.Errorf("got %v; want Exit", got)
+	}
+	h = e.Handler(func(info gotfp.FileInfo, depth int) gotfp.Action {
+		return gotfp.ActionContinue
+	})
+	if got := h(doc, 1); got != gotfp.ActionSkip {
+		t.Errorf("got %v; want Skip", got)
+	}
+	if got := h(gotfp.FileInfo{Path: filepath.Join(root, "a.go"), Cat: gotfp.RegularFile}, 1); got != gotfp.ActionContinue {
+		t.Errorf("got %v; want Continue", got)
+	}
+	if !e.Match(doc, 1) {
+		t.Error("doc not matched")
+	}
+}
@@ -0,0 +1,347 @@
+package findexpr
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/donyori/gotfp"
+)
+
+// tPrimaryDef defines a primary.
+type tPrimaryDef struct {
+	NumArgs int
+	// Compile returns the function evaluating the primary with args.
+	Compile func(p *tParser, args []string) (func(c *tEvalCtx) bool, error)
+}
+
+var primaries = map[string]tPrimaryDef{
+	"-name":  {1, compileName(false)},
+	"-iname": {1, compileName(true)},
+	"-path":  {1, compilePath(false)},
+	"-ipath": {1, compilePath(true)},
+	"-type":  {1, compileType},
+	"-size":  {1, compileSize},
+	"-mtime": {1, compileMtime(24 * time.Hour)},
+	"-mmin":  {1, compileMtime(time.Minute)},
+	"-newer": {1, compileNewer},
+	"-perm":  {1, compilePerm},
+	"-empty": {0, constant(isEmpty)},
+	"-true": {0, constant(func(c *tEvalCtx) bool {
+		return true
+	})},
+	"-false": {0, constant(func(c *tEvalCtx) bool {
+		return false
+	})},
+	"-prune": {0, constant(func(c *tEvalCtx) bool {
+		c.isPruned = true
+		return true
+	})},
+	"-print": {0, func(p *tParser, args []string) (
+		func(c *tEvalCtx) bool, error) {
+		p.expr.hasPrint = true
+		return func(c *tEvalCtx) bool {
+			c.print()
+			return true
+		}, nil
+	}},
+}
+
+func constant(f func(c *tEvalCtx) bool) func(p *tParser, args []string) (
+	func(c *tEvalCtx) bool, error) {
+	return func(p *tParser, args []string) (func(c *tEvalCtx) bool, error) {
+		return f, nil
+	}
+}
+
+func compileName(ignoreCase bool) func(p *tParser, args []string) (
+	func(c *tEvalCtx) bool, error) {
+	return func(p *tParser, args []string) (func(c *tEvalCtx) bool, error) {
+		re, err := compileGlob(args[0], ignoreCase)
+		if err != nil {
+			return nil, err
+		}
+		return func(c *tEvalCtx) bool {
+			return re.MatchString(filepath.Base(c.Info.Path))
+		}, nil
+	}
+}
+
+func compilePath(ignoreCase bool) func(p *tParser, args []string) (
+	func(c *tEvalCtx) bool, error) {
+	return func(p *tParser, args []string) (func(c *tEvalCtx) bool, error) {
+		re, err := compileGlob(args[0], ignoreCase)
+		if err != nil {
+			return nil, err
+		}
+		return func(c *tEvalCtx) bool {
+			return re.MatchString(filepath.ToSlash(c.displayPath()))
+		}, nil
+	}
+}
+
+// compileGlob compiles the shell pattern to a regular expression
+// matching the whole string, where '*' and '?' also match '/'.
+func compileGlob(pattern string, ignoreCase bool) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?s")
+	if ignoreCase {
+		b.WriteByte('i')
+	}
+	b.WriteString(")^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteByte('.')
+		case '\\':
+			if i++; i == len(pattern) {
+				return nil, errors.New("trailing backslash in pattern")
+			}
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case '[':
+			j := i + 1
+			if j < len(pattern) && (pattern[j] == '!' || pattern[j] == '^') {
+				j++
+			}
+			if j < len(pattern) && pattern[j] == ']' {
+				j++ // A leading ']' is literal.
+			}
+			for j < len(pattern) && pattern[j] != ']' {
+				j++
+			}
+			if j == len(pattern) {
+				return nil, errors.New("unterminated character class in pattern")
+			}
+			class := pattern[i+1 : j]
+			b.WriteByte('[')
+			if class[0] == '!' || class[0] == '^' {
+				b.WriteByte('^')
+				class = class[1:]
+			}
+			b.WriteString(strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`).Replace(class))
+			b.WriteByte(']')
+			i = j
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteByte('$')
+	return regexp.Compile(b.String())
+}
+
+func compileType(p *tParser, args []string) (func(c *tEvalCtx) bool, error) {
+	var types []func(c *tEvalCtx) bool
+	for _, t := range strings.Split(args[0], ",") {
+		var mode fs.FileMode
+		switch t {
+		case "f":
+			types = append(types, func(c *tEvalCtx) bool {
+				return c.Info.Cat == gotfp.RegularFile
+			})
+			continue
+		case "d":
+			types = append(types, func(c *tEvalCtx) bool {
+				return c.Info.Cat == gotfp.Directory
+			})
+			continue
+		case "l":
+			types = append(types, func(c *tEvalCtx) bool {
+				return c.Info.Cat == gotfp.Symlink
+			})
+			continue
+		case "p":
+			mode = fs.ModeNamedPipe
+		case "s":
+			mode = fs.ModeSocket
+		case "c":
+			mode = fs.ModeDevice | fs.ModeCharDevice
+		case "b":
+			mode = fs.ModeDevice
+		default:
+			return nil, errors.New("unknown type " + strconv.Quote(t) +
+				"; want f, d, l, p, s, c, or b")
+		}
+		types = append(types, func(c *tEvalCtx) bool {
+			if c.Info.Cat != gotfp.OtherFile {
+				return false
+			}
+			fi := c.loadInfo()
+			return fi != nil &&
+				fi.Mode()&(fs.ModeType|fs.ModeCharDevice) == mode
+		})
+	}
+	return func(c *tEvalCtx) bool {
+		for _, t := range types {
+			if t(c) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// parseNumber parses a number with an optional sign,
+// and returns its comparison: -1 for less than, 0 for exactly,
+// and 1 for more than.
+func parseNumber(s string) (cmp int, n int64, err error) {
+	switch {
+	case strings.HasPrefix(s, "+"):
+		cmp, s = 1, s[1:]
+	case strings.HasPrefix(s, "-"):
+		cmp, s = -1, s[1:]
+	}
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		return 0, 0, errors.New("invalid number")
+	}
+	n, err = strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, 0, errors.New("invalid number")
+	}
+	return
+}
+
+func compare(cmp int, x, n int64) bool {
+	switch cmp {
+	case -1:
+		return x < n
+	case 1:
+		return x > n
+	}
+	return x == n
+}
+
+var sizeUnits = map[byte]int64{
+	'c': 1,
+	'w': 2,
+	'b': 512,
+	'k': 1 << 10,
+	'M': 1 << 20,
+	'G': 1 << 30,
+}
+
+func compileSize(p *tParser, args []string) (func(c *tEvalCtx) bool, error) {
+	s, unit := args[0], int64(512)
+	if s != "" {
+		if u, ok := sizeUnits[s[len(s)-1]]; ok {
+			s, unit = s[:len(s)-1], u
+		}
+	}
+	cmp, n, err := parseNumber(s)
+	if err != nil {
+		return nil, errors.New("invalid size; want [+-]n[cwbkMG]")
+	}
+	return func(c *tEvalCtx) bool {
+		fi := c.loadInfo()
+		if fi == nil {
+			return false
+		}
+		// Rounded up, as find.
+		return compare(cmp, (fi.Size()+unit-1)/unit, n)
+	}, nil
+}
+
+func compileMtime(unit time.Duration) func(p *tParser, args []string) (
+	func(c *tEvalCtx) bool, error) {
+	return func(p *tParser, args []string) (func(c *tEvalCtx) bool, error) {
+		cmp, n, err := parseNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return func(c *tEvalCtx) bool {
+			fi := c.loadInfo()
+			if fi == nil {
+				return false
+			}
+			age := c.Expr.Now.Sub(fi.ModTime())
+			units := int64(age / unit)
+			if age < 0 && age%unit != 0 {
+				units-- // Rounded down.
+			}
+			return compare(cmp, units, n)
+		}, nil
+	}
+}
+
+func compileNewer(p *tParser, args []string) (func(c *tEvalCtx) bool, error) {
+	ref, err := os.Stat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	t := ref.ModTime()
+	return func(c *tEvalCtx) bool {
+		fi := c.loadInfo()
+		return fi != nil && fi.ModTime().After(t)
+	}, nil
+}
+
+func compilePerm(p *tParser, args []string) (func(c *tEvalCtx) bool, error) {
+	s, kind := args[0], byte(0)
+	if s != "" && (s[0] == '-' || s[0] == '/') {
+		s, kind = s[1:], s[0]
+	}
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || s == "" || mode > 0o7777 {
+		return nil, errors.New("invalid mode; want [-/] and octal digits, " +
+			"as symbolic modes are not supported")
+	}
+	m := uint32(mode)
+	return func(c *tEvalCtx) bool {
+		fi := c.loadInfo()
+		if fi == nil {
+			return false
+		}
+		bits := unixPerm(fi.Mode())
+		switch kind {
+		case '-':
+			return bits&m == m
+		case '/':
+			return m == 0 || bits&m != 0
+		}
+		return bits == m
+	}, nil
+}
+
+// unixPerm returns the permission bits of mode as in Unix,
+// including the setuid, setgid, and sticky bits.
+func unixPerm(mode fs.FileMode) uint32 {
+	bits := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		bits |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		bits |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		bits |= 0o1000
+	}
+	return bits
+}
+
+func isEmpty(c *tEvalCtx) bool {
+	switch c.Info.Cat {
+	case gotfp.RegularFile:
+		fi := c.loadInfo()
+		return fi != nil && fi.Size() == 0
+	case gotfp.Directory:
+		f, err := c.open()
+		if err != nil {
+			return false
+		}
+		defer f.Close() // Ignore error.
+		dir, ok := f.(fs.ReadDirFile)
+		if !ok {
+			return false
+		}
+		entries, err := dir.ReadDir(1)
+		return len(entries) == 0 && errors.Is(err, io.EOF)
+	}
+	return false
+}
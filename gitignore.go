@@ -0,0 +1,301 @@
+package gotfp
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	gitDirName    = ".git"
+	gitignoreName = ".gitignore"
+)
+
+type tIgnoreRule struct {
+	Elems   []string // Relative to the base of the stack. Never empty.
+	Negate  bool
+	DirOnly bool
+}
+
+// Rules from one source (a .gitignore file, .git/info/exclude,
+// or the global excludes file), along with the rules of lower precedence.
+// Stacks are immutable once created, so they can be shared by tasks
+// processed by different workers in any order.
+type tIgnoreStack struct {
+	Base   string // The directory that the rules are relative to.
+	Rules  []tIgnoreRule
+	Parent *tIgnoreStack
+}
+
+// push returns a new stack with rules relative to base on the top of s.
+// It returns s itself if there is no rule.
+func (s *tIgnoreStack) push(base string, rules []tIgnoreRule) *tIgnoreStack {
+	if len(rules) == 0 {
+		return s
+	}
+	return &tIgnoreStack{Base: base, Rules: rules, Parent: s}
+}
+
+// ignores reports whether the file is ignored.
+// As git does, the last matching rule in the deepest source decides.
+// Ensure path is under the base of every source in the stack.
+func (s *tIgnoreStack) ignores(path string, isDir bool) bool {
+	for ; s != nil; s = s.Parent {
+		rel := relElems(s.Base, path)
+		for i := len(s.Rules) - 1; i >= 0; i-- {
+			r := &s.Rules[i]
+			if r.DirOnly && !isDir {
+				continue
+			}
+			if matchGlobElems(r.Elems, rel) {
+				return !r.Negate
+			}
+		}
+	}
+	return false
+}
+
+// parseIgnoreRules parses patterns in the gitignore format.
+// Malformed patterns are dropped.
+func parseIgnoreRules(r io.Reader) ([]tIgnoreRule, error) {
+	var rules []tIgnoreRule
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, scanner.Err()
+}
+
+func parseIgnoreRule(line string) (rule tIgnoreRule, ok bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// Trailing spaces are ignored unless they are quoted with backslash.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return
+	}
+	if line[0] == '!' {
+		rule.Negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.DirOnly = true
+		line = line[:len(line)-1]
+	}
+	// A pattern with a slash at the beginning or in the middle is relative
+	// to the base. Otherwise, it matches at any level below the base.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return
+	}
+	elems := strings.Split(line, "/")
+	for i, elem := range elems {
+		if elem == "" {
+			return // Consecutive slashes never match.
+		}
+		if elem != "**" {
+			// "**" not being a whole element is the same as "*".
+			for strings.Contains(elem, "**") {
+				elem = strings.ReplaceAll(elem, "**", "*")
+			}
+			elem = strings.ReplaceAll(elem, "[!", "[^")
+			if _, err := path.Match(elem, ""); err != nil {
+				return
+			}
+			elems[i] = elem
+		}
+	}
+	if n := len(elems); n > 1 && elems[n-1] == "**" {
+		// A trailing "/**" matches everything inside, but not the directory itself.
+		elems = append(elems[:n-1], "*", "**")
+	}
+	if !anchored && elems[0] != "**" {
+		elems = append([]string{"**"}, elems...)
+	}
+	rule.Elems = elems
+	return rule, true
+}
+
+// readIgnoreFile returns the rules in the file,
+// or nil without error if the file does not exist.
+func readIgnoreFile(fsys tFileSystem, filename string) (
+	[]tIgnoreRule, error) {
+	f, err := fsys.Open(filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		return nil, err
+	}
+	defer f.Close() // Ignore error.
+	return parseIgnoreRules(f)
+}
+
+// gitGlobalExcludesFile returns the path to the global excludes file,
+// which is core.excludesFile in the global git configuration,
+// or $XDG_CONFIG_HOME/git/ignore if not set.
+// It returns an empty string if the path is unknown.
+func gitGlobalExcludesFile() string {
+	home, _ := os.UserHomeDir() // Ignore error.
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" && home != "" {
+		xdg = filepath.Join(home, ".config")
+	}
+	var configs []string
+	if xdg != "" {
+		configs = append(configs, filepath.Join(xdg, "git", "config"))
+	}
+	if home != "" {
+		// Read later as it takes precedence.
+		configs = append(configs, filepath.Join(home, ".gitconfig"))
+	}
+	var excludesFile string
+	for _, cfg := range configs {
+		if v := readGitConfigExcludesFile(cfg); v != "" {
+			excludesFile = v
+		}
+	}
+	if excludesFile == "" {
+		if xdg == "" {
+			return ""
+		}
+		return filepath.Join(xdg, "git", "ignore")
+	}
+	if strings.HasPrefix(excludesFile, "~/") && home != "" {
+		excludesFile = filepath.Join(home, excludesFile[2:])
+	}
+	return excludesFile
+}
+
+// readGitConfigExcludesFile returns the value of core.excludesFile in
+// the git configuration file, or an empty string if not found.
+// Only the simple "key = value" form is supported.
+func readGitConfigExcludesFile(filename string) string {
+	f, err := os.Open(filename)
+	if err != nil {
+		return ""
+	}
+	defer f.Close() // Ignore error.
+	var value string
+	var inCore bool
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			section := strings.TrimSpace(strings.Trim(line, "[]"))
+			inCore = strings.EqualFold(section, "core")
+			continue
+		}
+		if !inCore {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(k), "excludesFile") {
+			value = strings.Trim(strings.TrimSpace(v), "\"")
+		}
+	}
+	return value
+}
+
+// rootIgnore returns the stack for the root,
+// consisting of the global excludes file and, if the root is inside
+// a git repository, .git/info/exclude and the .gitignore files
+// from the top of the repository to the parent of the root.
+// The .gitignore in the root itself is loaded by dirIgnore.
+// Ensure tr.Cfg.gitignore is true.
+func (tr *tTraversal) rootIgnore(root string, errBuf *[]error) *tIgnoreStack {
+	if _, err := tr.FS.Lstat(tr.FS.Join(root, gitDirName)); err == nil {
+		// The root is the top of a repository, handled by dirIgnore.
+		return nil
+	}
+	var dirs []string // From the parent of root to the top of repository.
+	top := ""
+	for dir := tr.FS.Dir(root); dir != root; dir = tr.FS.Dir(dir) {
+		dirs = append(dirs, dir)
+		if _, err := tr.FS.Lstat(tr.FS.Join(dir, gitDirName)); err == nil {
+			top = dir
+			break
+		}
+		if tr.FS.Dir(dir) == dir {
+			break
+		}
+	}
+	if top == "" {
+		return (*tIgnoreStack)(nil).push(root, tr.GitGlobal)
+	}
+	s := tr.repoIgnore(top, errBuf)
+	for i := len(dirs) - 1; i >= 0; i-- {
+		s = tr.pushIgnoreFile(s, dirs[i], gitignoreName, errBuf)
+	}
+	return s
+}
+
+// dirIgnore returns the stack for the children of the directory.
+// If hasAllChldn is true, task.FileInfo.Chldn holds the names of all
+// children, so they are used to find the rule files instead of stat.
+// Ensure tr.Cfg.gitignore is true and task.FileInfo is a directory.
+func (tr *tTraversal) dirIgnore(task *tTask, hasAllChldn bool,
+	errBuf *[]error) *tIgnoreStack {
+	s := task.Ignore
+	var hasGitDir, hasGitignore bool
+	if hasAllChldn {
+		for _, name := range task.FileInfo.Chldn {
+			switch name {
+			case gitDirName:
+				hasGitDir = true
+			case gitignoreName:
+				hasGitignore = true
+			}
+		}
+	} else {
+		_, err := tr.FS.Lstat(tr.FS.Join(task.FileInfo.Path, gitDirName))
+		hasGitDir = err == nil
+		_, err = tr.FS.Lstat(tr.FS.Join(task.FileInfo.Path, gitignoreName))
+		hasGitignore = err == nil
+	}
+	return tr.pushDirIgnore(s, task.FileInfo.Path, hasGitDir, hasGitignore,
+		errBuf)
+}
+
+// pushDirIgnore returns the stack for the children of the directory dir,
+// given s for dir itself, and whether dir has a .git and a .gitignore.
+func (tr *tTraversal) pushDirIgnore(s *tIgnoreStack, dir string,
+	hasGitDir, hasGitignore bool, errBuf *[]error) *tIgnoreStack {
+	if hasGitDir {
+		// Rules outside a repository do not apply to it.
+		s = tr.repoIgnore(dir, errBuf)
+	}
+	if hasGitignore {
+		s = tr.pushIgnoreFile(s, dir, gitignoreName, errBuf)
+	}
+	return s
+}
+
+// repoIgnore returns the stack for the repository at top,
+// without its .gitignore files.
+func (tr *tTraversal) repoIgnore(top string, errBuf *[]error) *tIgnoreStack {
+	s := (*tIgnoreStack)(nil).push(top, tr.GitGlobal)
+	return tr.pushIgnoreFile(s, top,
+		tr.FS.Join(gitDirName, "info", "exclude"), errBuf)
+}
+
+func (tr *tTraversal) pushIgnoreFile(s *tIgnoreStack, dir, name string,
+	errBuf *[]error) *tIgnoreStack {
+	rules, err := readIgnoreFile(tr.FS, tr.FS.Join(dir, name))
+	if err != nil {
+		*errBuf = append(*errBuf, err)
+	}
+	return s.push(dir, rules)
+}
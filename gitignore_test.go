@@ -0,0 +1,100 @@
+package gotfp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTIgnoreStack_Ignores(t *testing.T) {
+	const base = "/repo"
+	testCases := []struct {
+		rules  string
+		path   string
+		isDir  bool
+		ignore bool
+	}{
+		{"*.log", "a.log", false, true},
+		{"*.log", "a/b/c.log", false, true},
+		{"*.log\n!keep.log", "a/keep.log", false, false},
+		{"/top.txt", "top.txt", false, true},
+		{"/top.txt", "a/top.txt", false, false},
+		{"a/b.txt", "a/b.txt", false, true},
+		{"a/b.txt", "x/a/b.txt", false, false},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"build/", "a/build", true, true},
+		{"doc/**", "doc", true, false},
+		{"doc/**", "doc/a/b.md", false, true},
+		{"**/foo/bar", "x/y/foo/bar", false, true},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"# comment", "# comment", false, false},
+		{"\\#hash", "#hash", false, true},
+		{"\\!bang", "!bang", false, true},
+		{"trailing   ", "trailing", false, true},
+		{"space\\ ", "space ", false, true},
+		{"[!a]bc", "xbc", false, true},
+		{"[!a]bc", "abc", false, false},
+		{"a**b", "axxb", false, true},
+	}
+	for _, tc := range testCases {
+		rules, err := parseIgnoreRules(strings.NewReader(tc.rules))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := (*tIgnoreStack)(nil).push(base, rules)
+		path := filepath.Join(base, filepath.FromSlash(tc.path))
+		if got := s.ignores(path, tc.isDir); got != tc.ignore {
+			t.Errorf("rules %q, path %q (dir: %t): got %t; want %t",
+				tc.rules, tc.path, tc.isDir, got, tc.ignore)
+		}
+	}
+}
+
+func TestTraverser_Files_Gitignore(t *testing.T) {
+	root := testMakeTree(t,
+		".git/info/exclude",
+		".git/HEAD",
+		"a.log",
+		"a.tmp",
+		"build/out.txt",
+		"keep.txt",
+		"secret",
+		"sub/debug.log",
+		"sub/trace.log",
+		"sub/x/build/y.txt",
+		"sub/x/z.txt",
+	)
+	write := func(name, content string) {
+		err := os.WriteFile(filepath.Join(root, filepath.FromSlash(name)),
+			[]byte(content), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".gitignore", "*.log\nbuild/\n")
+	write("sub/.gitignore", "!debug.log\n")
+	write(".git/info/exclude", "secret\n")
+	globalFile := filepath.Join(t.TempDir(), "ignore")
+	if err := os.WriteFile(globalFile, []byte("*.tmp\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tr := NewTraverser(WithGitignore(true), WithGitExcludesFile(globalFile))
+
+	got := testCollectFiles(t, tr, root)
+	want := []string{".", ".gitignore", "keep.txt", "sub", "sub/.gitignore",
+		"sub/debug.log", "sub/x", "sub/x/z.txt"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	// Rules above the root also apply.
+	sub := filepath.Join(root, "sub")
+	got = testCollectFiles(t, tr, sub)
+	want = []string{".", ".gitignore", "debug.log", "x", "x/z.txt"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,67 @@
+package gotfp
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchGlob reports whether name matches the shell pattern.
+//
+// Both pattern and name are slash-separated paths. Each element of pattern
+// is matched against an element of name with the syntax of path.Match,
+// except that an element "**" matches zero or more elements of name.
+// The pattern is anchored: it must match the whole name, not just its suffix.
+// A trailing slash in pattern is ignored by MatchGlob; Traverser uses it
+// to match directories only.
+//
+// The only possible returned error is path.ErrBadPattern,
+// when pattern is malformed.
+func MatchGlob(pattern, name string) (matched bool, err error) {
+	pattern = strings.TrimSuffix(pattern, "/")
+	if err = validateGlob(pattern); err != nil {
+		return false, err
+	}
+	return matchGlobElems(strings.Split(pattern, "/"),
+		strings.Split(name, "/")), nil
+}
+
+func validateGlob(pattern string) error {
+	for _, elem := range strings.Split(pattern, "/") {
+		if elem == "**" {
+			continue
+		}
+		if _, err := path.Match(elem, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ensure all elements of pattern are valid.
+func matchGlobElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Merge consecutive "**".
+			for len(pattern) > 1 && pattern[1] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := range name {
+				if matchGlobElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,97 @@
+package gotfp
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	testCases := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.go", "a.go", true},
+		{"*.go", "a/b.go", false},
+		{"**/*.go", "a.go", true},
+		{"**/*.go", "a/b/c.go", true},
+		{"a/**", "a", true},
+		{"a/**", "a/b/c", true},
+		{"a/**/c", "a/c", true},
+		{"a/**/c", "a/b/b/c", true},
+		{"a/**/c", "a/b/d", false},
+		{"a/**/**/c", "a/b/c", true},
+		{"a/?/c", "a/b/c", true},
+		{"a/[b-c]/c", "a/d/c", false},
+		{"src/", "src", true},
+		{"a", "b/a", false},
+	}
+	for _, tc := range testCases {
+		got, err := MatchGlob(tc.pattern, tc.name)
+		if err != nil {
+			t.Errorf("MatchGlob(%q, %q): %v", tc.pattern, tc.name, err)
+		} else if got != tc.want {
+			t.Errorf("MatchGlob(%q, %q) = %t; want %t",
+				tc.pattern, tc.name, got, tc.want)
+		}
+	}
+	if _, err := MatchGlob("a/[", "a/b"); err != path.ErrBadPattern {
+		t.Errorf("got error %v; want %v", err, path.ErrBadPattern)
+	}
+}
+
+func TestTraverser_Files_Filter(t *testing.T) {
+	root := testMakeTree(t, "a.go", "a.txt", "build/b.go", "src/c.go",
+		"src/build/d.go", "src/vendor/e.go", "src/vendor/f.txt", "vendor")
+	tr := NewTraverser(WithInclude("**/*.go"),
+		WithExclude("**/build/", "src/vendor"))
+	got := testCollectFiles(t, tr, root)
+	want := []string{".", "a.go", "src/c.go"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestTraverser_Batches_Filter(t *testing.T) {
+	root := testMakeTree(t, "a.go", "a.txt", "build/b.go", "src/c.go",
+		"src/build/d.go")
+	tr := NewTraverser(WithInclude("**/*.go"), WithExclude("**/build/"))
+	var mu sync.Mutex
+	var got []string
+	err := tr.Batches(context.Background(),
+		func(batch Batch, depth int) (Action, map[string]bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, fis := range [][]FileInfo{batch.RegFiles, batch.Dirs} {
+				for _, fi := range fis {
+					rel, _ := filepath.Rel(root, fi.Path)
+					got = append(got, filepath.ToSlash(rel))
+				}
+			}
+			return ActionContinue, nil
+		}, root)
+	if err != nil {
+		t.Error(err)
+	}
+	want := map[string]bool{"a.go": true, "src": true, "src/c.go": true}
+	if len(got) != len(want) {
+		t.Errorf("got %q; want %v", got, want)
+	}
+	for _, p := range got {
+		if !want[p] {
+			t.Errorf("got unexpected %q", p)
+		}
+	}
+}
+
+func TestWithInclude_BadPattern(t *testing.T) {
+	defer func() {
+		if recover() != path.ErrBadPattern {
+			t.Error("no path.ErrBadPattern panic")
+		}
+	}()
+	WithInclude("[")
+}
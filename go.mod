@@ -0,0 +1,5 @@
+module github.com/donyori/gotfp
+
+go 1.25
+
+require github.com/donyori/goctpf v0.2.0
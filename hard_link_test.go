@@ -0,0 +1,115 @@
+//go:build unix
+
+package gotfp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testMakeHardLinks makes a tree where a/x.txt, b/y.txt, and c.txt
+// are hard links to the same file, and d.txt is a plain file.
+func testMakeHardLinks(tb testing.TB) string {
+	tb.Helper()
+	root := testMakeTree(tb, "a/x.txt", "b/", "d.txt")
+	src := filepath.Join(root, "a", "x.txt")
+	for _, p := range []string{"b/y.txt", "c.txt"} {
+		if err := os.Link(src, filepath.Join(root, filepath.FromSlash(p))); err != nil {
+			tb.Skip("hard links unsupported:", err)
+		}
+	}
+	return root
+}
+
+func TestWithHardLinks(t *testing.T) {
+	root := testMakeHardLinks(t)
+	links := []string{"a/x.txt", "b/y.txt", "c.txt"}
+	for _, ordered := range []bool{false, true} {
+		opts := []Option{WithWorkerNumber(4)}
+		if ordered {
+			opts = append(opts, WithOrderedDelivery(2))
+		}
+		got := testCollectFiles(t,
+			NewTraverser(append(opts, WithHardLinks(KeepHardLinks))...), root)
+		if want := ".,a,a/x.txt,b,b/y.txt,c.txt,d.txt"; strings.Join(got, ",") != want {
+			t.Errorf("keep, ordered %t: got %v; want %s", ordered, got, want)
+		}
+		got = testCollectFiles(t,
+			NewTraverser(append(opts, WithHardLinks(SkipHardLinks))...), root)
+		var n int
+		for _, p := range got {
+			for _, link := range links {
+				if p == link {
+					n++
+				}
+			}
+		}
+		if n != 1 || len(got) != 5 {
+			t.Errorf("skip, ordered %t: got %v; want only one of %v", ordered, got, links)
+		}
+	}
+}
+
+func TestWithHardLinks_Mark(t *testing.T) {
+	root := testMakeHardLinks(t)
+	var mu sync.Mutex
+	var firsts []string
+	var usage, repeats int64
+	err := NewTraverser(WithWorkerNumber(4), WithHardLinks(MarkHardLinks)).Files(
+		context.Background(),
+		func(info FileInfo, depth int) Action {
+			mu.Lock()
+			defer mu.Unlock()
+			if info.Cat != RegularFile {
+				return ActionContinue
+			}
+			hl := info.HardLink
+			switch {
+			case filepath.Base(info.Path) == "d.txt":
+				if hl != nil {
+					t.Errorf("%q marked as hard link: %+v", info.Path, *hl)
+				}
+			case hl == nil:
+				t.Errorf("%q not marked as hard link", info.Path)
+				return ActionContinue
+			case hl.NumLinks != 3:
+				t.Errorf("%q: got %d links; want 3", info.Path, hl.NumLinks)
+			}
+			if hl != nil {
+				firsts = append(firsts, hl.FirstPath)
+				if hl.IsRepeat {
+					repeats++
+					return ActionContinue
+				}
+			}
+			usage += info.Info.Size()
+			return ActionContinue
+		}, root)
+	if err != nil {
+		t.Error(err)
+	}
+	if want := int64(len("a/x.txt") + len("d.txt")); usage != want {
+		t.Errorf("got usage %d; want %d", usage, want)
+	}
+	if repeats != 2 {
+		t.Errorf("got %d repeats; want 2", repeats)
+	}
+	sort.Strings(firsts)
+	if len(firsts) != 3 || firsts[0] != firsts[2] {
+		t.Errorf("got first paths %v; want the same one", firsts)
+	}
+}
+
+func TestWithHardLinks_Panic(t *testing.T) {
+	defer func() {
+		if _, ok := recover().(*UnknownHardLinkPolicyError); !ok {
+			t.Error("no UnknownHardLinkPolicyError")
+		}
+	}()
+	NewTraverser(WithHardLinks(0))
+}
@@ -1,13 +1,192 @@
 package gotfp
 
+import (
+	"io/fs"
+	"path"
+	"sync"
+
+	"github.com/donyori/gotfp/mountinfo"
+)
+
 // Traversing task.
 // One task for one file.
 type tTask struct {
 	FileInfo FileInfo
 	Depth    int
+	Root     string        // The root that this file is under.
+	Ignore   *tIgnoreStack // Gitignore rules for this file, or for its children if it is a directory.
 	ExInfo   interface{}
+
+	// For reading a directory chunk by chunk.
+	Chunk       int           // Index of the chunk. Tasks for chunks other than the first have the same depth as the directory.
+	DirReader   tDirReader    // Nil if there is no more chunk.
+	NextEntries []fs.DirEntry // The next chunk, read ahead.
+
+	// For categorizing the children of a wide directory in parallel.
+	// Tasks for shards have the same depth as the directory.
+	Shard *tStatShard
 }
 
 // There should be no nil *FInfo in "nextFiles"!
 type taskHandler func(task *tTask, errBuf *[]error) (
 	newTasks []*tTask, doesExit bool)
+
+// Traversing environment.
+// One for each traversal, shared by all its tasks.
+type tTraversal struct {
+	Cfg       *config
+	FS        tFileSystem
+	GitGlobal []tIgnoreRule // Rules in the global git excludes file.
+	Errs      tErrCollector
+	OpenDirs  tDirReaderSet // Directories being read chunk by chunk.
+	Prefetch  *tPrefetcher  // Reads directories ahead, only used in ordered delivery.
+	RootDevs  sync.Map      // Device numbers of roots, by root, only used to stay on their file systems.
+	Mounts    *mountinfo.Table
+	Links     *tFileIDSet // Files with several hard links seen, only used with SkipHardLinks or MarkHardLinks.
+
+	// Whether to collect the errors of ErrorFile into Errs.
+	CollectFileErrs bool
+}
+
+// Ensure cfg != nil.
+func newTraversal(cfg *config) *tTraversal {
+	if cfg.maxBuffered > 0 && (cfg.chunkSize > 0 || cfg.parallelStatThreshold > 0) {
+		// Directories are read as a whole, by the prefetcher, in ordered delivery.
+		c := *cfg
+		c.chunkSize, c.parallelStatThreshold = 0, 0
+		cfg = &c
+	}
+	tr := &tTraversal{Cfg: cfg, FS: cfg.fileSystem(), CollectFileErrs: true}
+	tr.Errs.Max = cfg.maxErrors
+	if cfg.maxBuffered > 0 {
+		tr.Prefetch = newPrefetcher(tr, cfg.maxBuffered)
+	}
+	if (cfg.isMountInfoSet || len(cfg.fsTypeExcludes) > 0) && cfg.fsys == nil {
+		name := cfg.mountInfoPath
+		if name == "" {
+			name = mountinfo.DefaultPath
+		}
+		mounts, err := mountinfo.ReadFile(name)
+		if err != nil {
+			tr.Errs.add(name, err)
+		} else {
+			tr.Mounts = mountinfo.NewTable(mounts)
+		}
+	}
+	if cfg.hardLinks == SkipHardLinks || cfg.hardLinks == MarkHardLinks {
+		tr.Links = new(tFileIDSet)
+	}
+	if cfg.gitignore {
+		excludesFile := cfg.gitExcludesFile
+		if !cfg.isGitExcludesFileSet {
+			excludesFile = gitGlobalExcludesFile()
+		}
+		if excludesFile != "" {
+			// Ignore error as git does.
+			tr.GitGlobal, _ = readIgnoreFile(osFileSystem, excludesFile)
+		}
+	}
+	return tr
+}
+
+// tDirChain is a directory followed by its ancestors in the traversal,
+// to detect cycles of symbolic links.
+type tDirChain struct {
+	ID     tFileID
+	Path   string
+	Parent *tDirChain
+}
+
+// find returns the path of the directory in c identified by id.
+// A nil c contains no directory.
+func (c *tDirChain) find(id tFileID) (path string, ok bool) {
+	for ; c != nil; c = c.Parent {
+		if c.ID == id {
+			return c.Path, true
+		}
+	}
+	return "", false
+}
+
+// handles reports whether the handler should be called for the file
+// of task, according to the filter and the minimum depth.
+func (tr *tTraversal) handles(task *tTask, isDir bool) bool {
+	return task.Depth >= tr.Cfg.minDepth &&
+		tr.Cfg.filter.includes(task.Root, task.FileInfo.Path, isDir)
+}
+
+// reads reports whether a directory at depth should be read,
+// according to the maximum depth.
+func (tr *tTraversal) reads(depth int) bool {
+	return tr.Cfg.reads(depth)
+}
+
+// descends reports whether the file of task is a directory to read.
+func (tr *tTraversal) descends(task *tTask) bool {
+	return tr.Cfg.descends(task.FileInfo, task.Depth)
+}
+
+// fileSystem returns the file system to traverse.
+func (cfg *config) fileSystem() tFileSystem {
+	if cfg.fsys != nil {
+		return tFSFileSystem{FS: cfg.fsys}
+	}
+	return osFileSystem
+}
+
+// reads reports whether a directory at depth should be read,
+// according to the maximum depth.
+func (cfg *config) reads(depth int) bool {
+	return cfg.maxDepth < 0 || depth < cfg.maxDepth
+}
+
+// descends reports whether the file at depth is a directory to read.
+// The handler may still skip it.
+func (cfg *config) descends(info FileInfo, depth int) bool {
+	return info.Cat == Directory && !info.MountPoint && cfg.reads(depth)
+}
+
+// initRoot sets up the task of a root, whose FileInfo has only the path.
+func (tr *tTraversal) initRoot(task *tTask, errBuf *[]error) {
+	path := task.FileInfo.Path
+	// Roots are never excluded.
+	task.FileInfo, _ = getFileInfo(path, nil, tr, nil, true)
+	if tr.Cfg.gitignore {
+		task.Ignore = tr.rootIgnore(path, errBuf)
+	}
+	if tr.Cfg.sameFileSystem {
+		if dev, ok := getDeviceID(task.FileInfo.Info); ok {
+			tr.RootDevs.LoadOrStore(task.Root, dev)
+		}
+	}
+}
+
+// excludesMount reports whether the directory with the mount m
+// should be excluded by its file system type. A nil m returns false.
+func (tr *tTraversal) excludesMount(m *mountinfo.Mount) bool {
+	if m == nil {
+		return false
+	}
+	for _, pattern := range tr.Cfg.fsTypeExcludes {
+		if ok, _ := path.Match(pattern, m.FSType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// excludes reports whether the file in the directory of parent
+// should be neither reported nor read.
+func (tr *tTraversal) excludes(parent *tTask, path string, isDir bool) bool {
+	if tr == nil || parent == nil {
+		return false
+	}
+	if tr.Cfg.filter.excludes(parent.Root, path, isDir) {
+		return true
+	}
+	if tr.Cfg.gitignore {
+		return tr.FS.Base(path) == gitDirName ||
+			parent.Ignore.ignores(path, isDir)
+	}
+	return false
+}
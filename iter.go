@@ -0,0 +1,123 @@
+package gotfp
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sync/atomic"
+)
+
+// FileSeq returns an iterator over files in roots,
+// along with their errors (FileInfo.Err).
+// Files are visited by the workers of t in parallel, so their order is
+// unspecified, unless t delivers them in order (see WithOrderedDelivery).
+//
+// Breaking out of the loop stops the traversal, and all workers exit
+// before the loop ends. If any error other than those of files occurs,
+// or the traversal is stopped by ctx, the last pair yielded is a zero
+// FileInfo and the error, as returned by Traverser.Files but without
+// the errors of files.
+func (t *Traverser) FileSeq(ctx context.Context,
+	roots ...string) iter.Seq2[FileInfo, error] {
+	if ctx == nil {
+		panic(errors.New("gotfp: context is nil"))
+	}
+	return func(yield func(FileInfo, error) bool) {
+		err, isBroken := runSeq(ctx,
+			func(ctx context.Context, send func(FileInfo) bool) error {
+				return t.files(ctx, func(info FileInfo, depth int) Action {
+					if !send(info) {
+						return ActionExit
+					}
+					return ActionContinue
+				}, false, roots)
+			},
+			func(info FileInfo) bool {
+				return yield(info, info.Err)
+			})
+		if err != nil && !isBroken {
+			yield(FileInfo{}, err)
+		}
+	}
+}
+
+// BatchSeq returns an iterator over batches in roots,
+// along with nil errors.
+// Batches are visited by the workers of t in parallel, so their order is
+// unspecified, unless t delivers them in order (see WithOrderedDelivery).
+//
+// Breaking out of the loop stops the traversal, and all workers exit
+// before the loop ends. If any error other than those of files occurs,
+// or the traversal is stopped by ctx, the last pair yielded is a zero
+// Batch and the error, as returned by Traverser.Batches but without
+// the errors of files (which are in the batches).
+func (t *Traverser) BatchSeq(ctx context.Context,
+	roots ...string) iter.Seq2[Batch, error] {
+	if ctx == nil {
+		panic(errors.New("gotfp: context is nil"))
+	}
+	return func(yield func(Batch, error) bool) {
+		err, isBroken := runSeq(ctx,
+			func(ctx context.Context, send func(Batch) bool) error {
+				return t.batches(ctx, func(batch Batch, depth int) (
+					Action, map[string]bool) {
+					if !send(batch) {
+						return ActionExit, nil
+					}
+					return ActionContinue, nil
+				}, false, roots)
+			},
+			func(batch Batch) bool {
+				return yield(batch, nil)
+			})
+		if err != nil && !isBroken {
+			yield(Batch{}, err)
+		}
+	}
+}
+
+// runSeq calls traverse in a new goroutine, and yields values sent by it
+// in the current goroutine.
+// send returns false if the consumer has stopped, and then traverse should
+// return as soon as possible.
+// It returns after traverse returns, with the error returned by traverse,
+// or ctx.Err() if traverse returns nil after send has returned false
+// because ctx is done, and whether the consumer has stopped.
+func runSeq[T any](ctx context.Context,
+	traverse func(ctx context.Context, send func(T) bool) error,
+	yield func(T) bool) (err error, isBroken bool) {
+	seqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	c := make(chan T)
+	errChan := make(chan error, 1)
+	var isCancelled atomic.Bool
+	go func() {
+		defer close(c)
+		errChan <- traverse(seqCtx, func(v T) bool {
+			select {
+			case c <- v:
+				return true
+			case <-seqCtx.Done():
+				isCancelled.Store(true)
+				return false
+			}
+		})
+	}()
+	for v := range c {
+		if !yield(v) {
+			isBroken = true
+			cancel()
+			// Wait for the workers to exit.
+			for range c {
+			}
+			break
+		}
+	}
+	err = <-errChan
+	if err == nil && !isBroken && isCancelled.Load() {
+		// The traversal is stopped by ActionExit, returned for the value
+		// that cannot be sent, rather than by ctx.
+		err = ctx.Err()
+	}
+	return err, isBroken
+}
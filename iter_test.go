@@ -0,0 +1,126 @@
+package gotfp
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTraverser_FileSeq(t *testing.T) {
+	root := testMakeTree(t, "a/b.txt", "c.txt")
+	var got []string
+	tr := NewTraverser(WithWorkerNumber(4))
+	for info, err := range tr.FileSeq(context.Background(), root) {
+		if err != nil {
+			t.Error(err)
+		}
+		got = append(got, strings.TrimPrefix(info.Path, root))
+	}
+	sort.Strings(got)
+	want := []string{"", "/a", "/a/b.txt", "/c.txt"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestTraverser_FileSeq_Break(t *testing.T) {
+	numGoroutine := runtime.NumGoroutine()
+	tr := NewTraverser(WithWorkerNumber(testMaxProcs))
+	var n int
+	for range tr.FileSeq(context.Background(), testRoot) {
+		n++
+		if n == 100 {
+			break
+		}
+	}
+	if n != 100 {
+		t.Errorf("got %d files; want 100", n)
+	}
+	// Goroutines exited before the loop ended, except for those
+	// of the runtime that may still be finishing.
+	for i := 0; runtime.NumGoroutine() > numGoroutine && i < 100; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if g := runtime.NumGoroutine(); g > numGoroutine {
+		t.Errorf("%d goroutines leaked", g-numGoroutine)
+	}
+}
+
+func TestTraverser_FileSeq_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var lastErr error
+	var n int
+	for _, err := range NewTraverser().FileSeq(ctx, testRoot) {
+		n++
+		if n == 10 {
+			cancel()
+		}
+		lastErr = err
+	}
+	if !errors.Is(lastErr, context.Canceled) {
+		t.Errorf("got last error %v; want %v", lastErr, context.Canceled)
+	}
+}
+
+func TestTraverser_FileSeq_CancelBetweenYields(t *testing.T) {
+	root := testMakeTree(t, "a.txt", "b.txt", "c.txt", "d.txt")
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		var lastErr error
+		var n int
+		for info, err := range NewTraverser().FileSeq(ctx, root) {
+			n++
+			if n == 2 {
+				// Let the workers block in sending the next files.
+				time.Sleep(10 * time.Millisecond)
+				cancel()
+			}
+			if info.Path == "" {
+				lastErr = err
+			}
+		}
+		cancel()
+		if !errors.Is(lastErr, context.Canceled) {
+			t.Fatalf("yielded %d, got final error %v; want %v",
+				n, lastErr, context.Canceled)
+		}
+	}
+}
+
+func TestTraverser_BatchSeq(t *testing.T) {
+	root := testMakeTree(t, "a/b.txt", "c.txt", "d/")
+	var numBatch, numRegFile int
+	for batch, err := range NewTraverser().BatchSeq(context.Background(), root) {
+		if err != nil {
+			t.Error(err)
+		}
+		numBatch++
+		numRegFile += len(batch.RegFiles)
+	}
+	if numBatch != 3 || numRegFile != 2 {
+		t.Errorf("got %d batches, %d regular files; want 3, 2",
+			numBatch, numRegFile)
+	}
+}
+
+func TestTraverser_BatchSeq_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var lastErr error
+	var n int
+	for _, err := range NewTraverser().BatchSeq(ctx, testRoot) {
+		n++
+		if n == 10 {
+			cancel()
+		}
+		lastErr = err
+	}
+	if !errors.Is(lastErr, context.Canceled) {
+		t.Errorf("got last error %v; want %v", lastErr, context.Canceled)
+	}
+}
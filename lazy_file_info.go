@@ -0,0 +1,68 @@
+package gotfp
+
+import (
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// tLazyFileInfo is an fs.FileInfo built on a directory entry.
+// Name, IsDir and the type bits of Mode come from the entry,
+// and the rest is loaded on first use by fs.DirEntry.Info.
+// If loading fails, the methods return zero values except for those above.
+type tLazyFileInfo struct {
+	entry fs.DirEntry
+	once  sync.Once
+	info  fs.FileInfo
+	err   error
+}
+
+func newLazyFileInfo(entry fs.DirEntry) *tLazyFileInfo {
+	return &tLazyFileInfo{entry: entry}
+}
+
+func (lfi *tLazyFileInfo) load() (fs.FileInfo, error) {
+	lfi.once.Do(func() {
+		lfi.info, lfi.err = lfi.entry.Info()
+		if lfi.err == nil && lfi.info == nil {
+			lfi.err = fs.ErrInvalid
+		}
+	})
+	return lfi.info, lfi.err
+}
+
+func (lfi *tLazyFileInfo) Name() string {
+	return lfi.entry.Name()
+}
+
+func (lfi *tLazyFileInfo) Size() int64 {
+	if info, err := lfi.load(); err == nil {
+		return info.Size()
+	}
+	return 0
+}
+
+func (lfi *tLazyFileInfo) Mode() fs.FileMode {
+	if info, err := lfi.load(); err == nil {
+		return info.Mode()
+	}
+	return lfi.entry.Type()
+}
+
+func (lfi *tLazyFileInfo) ModTime() time.Time {
+	if info, err := lfi.load(); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+func (lfi *tLazyFileInfo) IsDir() bool {
+	return lfi.entry.IsDir()
+}
+
+func (lfi *tLazyFileInfo) Sys() interface{} {
+	if info, err := lfi.load(); err == nil {
+		return info.Sys()
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package gotfp
+
+import (
+	"context"
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// testReadDirCounter counts the calls to ReadDir for each directory.
+type testReadDirCounter struct {
+	fstest.MapFS
+	mu      sync.Mutex
+	counter map[string]int
+}
+
+func (c *testReadDirCounter) ReadDir(name string) ([]fs.DirEntry, error) {
+	c.mu.Lock()
+	if c.counter == nil {
+		c.counter = make(map[string]int)
+	}
+	c.counter[name]++
+	c.mu.Unlock()
+	return c.MapFS.ReadDir(name)
+}
+
+func testMakeReadDirCounter() *testReadDirCounter {
+	return &testReadDirCounter{MapFS: fstest.MapFS{
+		"skip/a/b/c.txt": {},
+		"skip/d.txt":     {},
+		"keep/e/f.txt":   {},
+		"keep/g.txt":     {},
+	}}
+}
+
+func TestTraverser_Files_LazyListing(t *testing.T) {
+	fsys := testMakeReadDirCounter()
+	err := NewTraverser(WithFS(fsys)).Files(context.Background(),
+		func(info FileInfo, depth int) Action {
+			if len(info.Chldn) > 0 {
+				t.Errorf("%q: children have been read", info.Path)
+			}
+			if info.Path == "skip" {
+				return ActionSkip
+			}
+			return ActionContinue
+		}, ".")
+	if err != nil {
+		t.Error(err)
+	}
+	want := map[string]int{".": 1, "keep": 1, "keep/e": 1}
+	if len(fsys.counter) != len(want) {
+		t.Errorf("got %v; want %v", fsys.counter, want)
+	}
+	for k, v := range want {
+		if fsys.counter[k] != v {
+			t.Errorf("%q: read %d times; want %d", k, fsys.counter[k], v)
+		}
+	}
+}
+
+func TestTraverser_Batches_LazyListing(t *testing.T) {
+	fsys := testMakeReadDirCounter()
+	err := NewTraverser(WithFS(fsys)).Batches(context.Background(),
+		func(batch Batch, depth int) (Action, map[string]bool) {
+			if batch.Parent.Path == "." {
+				return ActionSkip, map[string]bool{"skip": true}
+			}
+			return ActionContinue, nil
+		}, ".")
+	if err != nil {
+		t.Error(err)
+	}
+	want := map[string]int{".": 1, "keep": 1, "keep/e": 1}
+	if len(fsys.counter) != len(want) {
+		t.Errorf("got %v; want %v", fsys.counter, want)
+	}
+	for k, v := range want {
+		if fsys.counter[k] != v {
+			t.Errorf("%q: read %d times; want %d", k, fsys.counter[k], v)
+		}
+	}
+}
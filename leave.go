@@ -0,0 +1,75 @@
+package gotfp
+
+import "sync"
+
+// tLeaveNode records the values of the children of a directory
+// descended into, until all of them are done.
+type tLeaveNode struct {
+	FileInfo FileInfo
+	Depth    int
+	Parent   *tLeaveNode // Nil for a root.
+	Idx      int         // Index of the directory in the values of Parent.
+
+	mu        sync.Mutex
+	values    []interface{}
+	hasValues []bool
+	pending   int // Children not done yet, plus one until the last chunk is read.
+}
+
+// tLeaveLink is the ExInfo of a task traversed with a LeaveHandler.
+type tLeaveLink struct {
+	Parent *tLeaveNode // Nil for a root.
+	Idx    int         // Index of the file in the values of Parent.
+	Node   *tLeaveNode // Set when the file is a directory descended into.
+}
+
+func newLeaveNode(task *tTask, link *tLeaveLink) *tLeaveNode {
+	return &tLeaveNode{
+		FileInfo: task.FileInfo,
+		Depth:    task.Depth,
+		Parent:   link.Parent,
+		Idx:      link.Idx,
+		pending:  1,
+	}
+}
+
+// grow adds n children to node, and returns the index of the first one.
+func (node *tLeaveNode) grow(n int) int {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	base := len(node.values)
+	node.values = append(node.values, make([]interface{}, n)...)
+	node.hasValues = append(node.hasValues, make([]bool, n)...)
+	node.pending += n
+	return base
+}
+
+// done records the value of the idx-th child of node, if hasValue is true,
+// and then calls leave for each directory whose last child is done,
+// from node up to the root.
+// idx is ignored if it is negative, which stands for the last chunk
+// of node having been read.
+// A nil node does nothing.
+func (node *tLeaveNode) done(idx int, value interface{}, hasValue bool,
+	leave LeaveHandler) {
+	for node != nil {
+		node.mu.Lock()
+		if idx >= 0 && hasValue {
+			node.values[idx], node.hasValues[idx] = value, true
+		}
+		node.pending--
+		isLast := node.pending == 0
+		node.mu.Unlock()
+		if !isLast {
+			return
+		}
+		var values []interface{}
+		for i := range node.values {
+			if node.hasValues[i] {
+				values = append(values, node.values[i])
+			}
+		}
+		value, hasValue = leave(node.FileInfo, node.Depth, values), true
+		node, idx = node.Parent, node.Idx
+	}
+}
@@ -0,0 +1,115 @@
+package gotfp
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTraverser_FilesWithLeave(t *testing.T) {
+	// Each file contains its path relative to root (see testMakeTree).
+	paths := []string{"a/b/c.txt", "a/b/d.txt", "a/e.txt", "f/", "g.txt",
+		"h/i/j/k.txt", "skip/l.txt"}
+	root := testMakeTree(t, paths...)
+	size := func(ps ...string) int64 {
+		var n int
+		for _, p := range ps {
+			n += len(p)
+		}
+		return int64(n)
+	}
+	want := map[string]int64{
+		".":     size("a/b/c.txt", "a/b/d.txt", "a/e.txt", "g.txt", "h/i/j/k.txt"),
+		"a":     size("a/b/c.txt", "a/b/d.txt", "a/e.txt"),
+		"a/b":   size("a/b/c.txt", "a/b/d.txt"),
+		"f":     0,
+		"h":     size("h/i/j/k.txt"),
+		"h/i":   size("h/i/j/k.txt"),
+		"h/i/j": size("h/i/j/k.txt"),
+	}
+	testCases := []struct {
+		name string
+		opts []Option
+	}{
+		{"default", nil},
+		{"chunk", []Option{WithChunkSize(1)}},
+		{"parallel-stat", []Option{WithParallelStat(1)}},
+		{"ordered", []Option{WithOrderedDelivery(2)}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var mu sync.Mutex
+			entered := make(map[string]bool)
+			got := make(map[string]int64)
+			opts := append([]Option{WithWorkerNumber(4)}, tc.opts...)
+			err := NewTraverser(opts...).FilesWithLeave(context.Background(),
+				func(info FileInfo, depth int) (Action, interface{}) {
+					mu.Lock()
+					entered[info.Path] = true
+					mu.Unlock()
+					if filepath.Base(info.Path) == "skip" {
+						return ActionSkip, int64(0)
+					}
+					return ActionContinue, info.Info.Size()
+				},
+				func(dir FileInfo, depth int, childValues []interface{}) interface{} {
+					rel, err := filepath.Rel(root, dir.Path)
+					if err != nil {
+						t.Error(err)
+					}
+					rel = filepath.ToSlash(rel)
+					var total int64
+					for _, v := range childValues {
+						total += v.(int64)
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					for _, p := range paths {
+						if strings.HasPrefix(p, "skip/") {
+							continue
+						}
+						p = filepath.Join(root, strings.TrimSuffix(p, "/"))
+						if strings.HasPrefix(p, dir.Path) && !entered[p] {
+							t.Errorf("%q left before %q entered", dir.Path, p)
+						}
+					}
+					if _, ok := got[rel]; ok {
+						t.Errorf("%q left more than once", rel)
+					}
+					got[rel] = total
+					return total
+				}, root)
+			if err != nil {
+				t.Error(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestTraverser_FilesWithLeave_Values(t *testing.T) {
+	root := testMakeTree(t, "a.txt", "b/", "c.txt", "d.txt")
+	var got []interface{}
+	err := NewTraverser(WithInclude("*.txt", "b/"), WithExclude("c.txt")).FilesWithLeave(
+		context.Background(),
+		func(info FileInfo, depth int) (Action, interface{}) {
+			return ActionContinue, filepath.Base(info.Path)
+		},
+		func(dir FileInfo, depth int, childValues []interface{}) interface{} {
+			if depth == 0 {
+				got = childValues
+			}
+			return "dir"
+		}, root)
+	if err != nil {
+		t.Error(err)
+	}
+	if want := "[a.txt dir d.txt]"; fmt.Sprint(got) != want {
+		t.Errorf("got %v; want %s", got, want)
+	}
+}
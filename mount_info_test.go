@@ -0,0 +1,72 @@
+package gotfp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWithExcludeFSTypes(t *testing.T) {
+	root := testMakeTree(t, "a/b.txt", "c/d.txt", "e/f/g.txt")
+	name := filepath.Join(t.TempDir(), "mountinfo")
+	table := fmt.Sprintf(`1 0 8:1 / / rw - ext4 /dev/sda1 rw
+2 1 0:40 / %s rw - fuse.sshfs u@host:/ rw
+3 1 0:41 / %s rw - tmpfs tmpfs rw
+4 1 0:42 / %s rw - proc proc rw
+`, filepath.Join(root, "a"), filepath.Join(root, "c"), root)
+	if err := os.WriteFile(name, []byte(table), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, maxBuffered := range []int{0, 100} {
+		var mu sync.Mutex
+		var files []string
+		mounts := make(map[string]string)
+		err := NewTraverser(WithMountInfo(name),
+			WithExcludeFSTypes("fuse.*", "proc"),
+			WithOrderedDelivery(maxBuffered)).Files(context.Background(),
+			func(info FileInfo, depth int) Action {
+				rel, _ := filepath.Rel(root, info.Path)
+				mu.Lock()
+				defer mu.Unlock()
+				files = append(files, filepath.ToSlash(rel))
+				if info.Mount != nil {
+					mounts[filepath.ToSlash(rel)] = info.Mount.FSType
+				}
+				return ActionContinue
+			}, root)
+		if err != nil {
+			t.Error(err)
+		}
+		sort.Strings(files)
+		// The root is never excluded, even if its type is excluded.
+		if got, want := strings.Join(files, ","),
+			".,c,c/d.txt,e,e/f,e/f/g.txt"; got != want {
+			t.Errorf("got files %s; want %s", got, want)
+		}
+		if got, want := fmt.Sprint(mounts), "map[.:proc c:tmpfs]"; got != want {
+			t.Errorf("got mounts %s; want %s", got, want)
+		}
+	}
+}
+
+func TestWithMountInfo_Error(t *testing.T) {
+	root := testMakeTree(t, "a.txt")
+	var n int
+	err := NewTraverser(WithMountInfo(filepath.Join(root, "no-such-file"))).Files(
+		context.Background(), func(info FileInfo, depth int) Action {
+			n++
+			return ActionContinue
+		}, root)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got %v; want an error of the missing mount table", err)
+	}
+	if n != 2 {
+		t.Errorf("got %d files; want 2", n)
+	}
+}
@@ -0,0 +1,179 @@
+// Package mountinfo parses the mount table of Linux in the format of
+// /proc/<pid>/mountinfo, as described in proc(5).
+package mountinfo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultPath is the mount table of the current process.
+const DefaultPath = "/proc/self/mountinfo"
+
+// Mount is a line of the mount table.
+type Mount struct {
+	ID           int
+	ParentID     int
+	Major        uint32
+	Minor        uint32
+	Root         string   // Root of the mount within the file system.
+	MountPoint   string   // Mount point relative to the root of the process.
+	Options      []string // Per-mount options.
+	Optional     []string // Optional fields, e.g., "shared:1".
+	FSType       string   // Type of file system, e.g., "ext4" and "fuse.sshfs".
+	Source       string   // Source of the file system, e.g., "/dev/sda1".
+	SuperOptions []string // Per-superblock options.
+}
+
+// SyntaxError is reported when a line of the mount table is malformed.
+type SyntaxError struct {
+	Line int    // Line number, from 1.
+	Text string // The malformed line.
+	Msg  string
+}
+
+func (se *SyntaxError) Error() string {
+	return fmt.Sprintf("mountinfo: line %d: %s: %q", se.Line, se.Msg, se.Text)
+}
+
+// Parse parses the mount table from r.
+// It returns a *SyntaxError for a malformed line.
+func Parse(r io.Reader) ([]*Mount, error) {
+	var mounts []*Mount
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		m, msg := parseLine(line)
+		if msg != "" {
+			return mounts, &SyntaxError{Line: lineNo, Text: line, Msg: msg}
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, scanner.Err()
+}
+
+// ReadFile parses the mount table in the file name.
+// An empty name stands for DefaultPath.
+func ReadFile(name string) ([]*Mount, error) {
+	if name == "" {
+		name = DefaultPath
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // Ignore error.
+	return Parse(f)
+}
+
+// parseLine parses a line, or returns the message of the syntax error.
+func parseLine(line string) (m *Mount, msg string) {
+	fields := strings.Fields(line)
+	sep := -1
+	for i := 6; i < len(fields); i++ {
+		if fields[i] == "-" {
+			sep = i
+			break
+		}
+	}
+	if sep < 0 || len(fields) < sep+3 {
+		return nil, "too few fields"
+	}
+	m = &Mount{
+		Root:       unescape(fields[3]),
+		MountPoint: unescape(fields[4]),
+		Options:    strings.Split(fields[5], ","),
+		FSType:     fields[sep+1],
+		Source:     unescape(fields[sep+2]),
+	}
+	var err error
+	if m.ID, err = strconv.Atoi(fields[0]); err != nil {
+		return nil, "bad mount ID"
+	}
+	if m.ParentID, err = strconv.Atoi(fields[1]); err != nil {
+		return nil, "bad parent ID"
+	}
+	major, minor, ok := strings.Cut(fields[2], ":")
+	if !ok {
+		return nil, "bad device number"
+	}
+	majorNum, err1 := strconv.ParseUint(major, 10, 32)
+	minorNum, err2 := strconv.ParseUint(minor, 10, 32)
+	if err1 != nil || err2 != nil {
+		return nil, "bad device number"
+	}
+	m.Major, m.Minor = uint32(majorNum), uint32(minorNum)
+	if sep > 6 {
+		m.Optional = fields[6:sep]
+	}
+	if len(fields) > sep+3 {
+		m.SuperOptions = strings.Split(fields[sep+3], ",")
+	}
+	return m, ""
+}
+
+// unescape decodes the octal escapes (e.g., "\040" for a space)
+// in a field of the mount table.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && isOctal(s[i+1]) &&
+			isOctal(s[i+2]) && isOctal(s[i+3]) {
+			b.WriteByte((s[i+1]-'0')<<6 | (s[i+2]-'0')<<3 | (s[i+3] - '0'))
+			i += 3
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
+
+// Table looks up the mounts by mount point.
+type Table struct {
+	mounts map[string]*Mount
+}
+
+// NewTable makes a Table of mounts.
+// If several mounts are on the same mount point, the last one is used,
+// which is the one on top.
+func NewTable(mounts []*Mount) *Table {
+	t := &Table{mounts: make(map[string]*Mount, len(mounts))}
+	for _, m := range mounts {
+		t.mounts[m.MountPoint] = m
+	}
+	return t
+}
+
+// Lookup returns the mount on the mount point path,
+// or nil if path is not a mount point.
+// path should be absolute and clean.
+// A nil t returns nil.
+func (t *Table) Lookup(path string) *Mount {
+	if t == nil {
+		return nil
+	}
+	return t.mounts[path]
+}
+
+// Len returns the number of mount points in t.
+func (t *Table) Len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.mounts)
+}
@@ -0,0 +1,84 @@
+package mountinfo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testTable = `22 1 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw
+36 35 98:0 /mnt1 /mnt\0402 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+40 22 0:35 / /proc/sys/fs/binfmt_misc rw,relatime shared:13 - autofs systemd-1 rw,fd=29
+41 1 0:36 / /home/u/remote rw,nosuid,nodev,relatime - fuse.sshfs u@host:/ rw,user_id=1000
+42 1 0:37 / /home/u/remote rw - tmpfs tmpfs rw
+`
+
+func TestParse(t *testing.T) {
+	mounts, err := Parse(strings.NewReader(testTable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mounts) != 5 {
+		t.Fatalf("got %d mounts; want 5", len(mounts))
+	}
+	want := &Mount{
+		ID:           36,
+		ParentID:     35,
+		Major:        98,
+		Minor:        0,
+		Root:         "/mnt1",
+		MountPoint:   "/mnt 2",
+		Options:      []string{"rw", "noatime"},
+		Optional:     []string{"master:1"},
+		FSType:       "ext3",
+		Source:       "/dev/root",
+		SuperOptions: []string{"rw", "errors=continue"},
+	}
+	if !reflect.DeepEqual(mounts[1], want) {
+		t.Errorf("got %+v; want %+v", mounts[1], want)
+	}
+	if mounts[3].FSType != "fuse.sshfs" || mounts[3].Optional != nil {
+		t.Errorf("got %+v", mounts[3])
+	}
+}
+
+func TestParse_SyntaxError(t *testing.T) {
+	for _, line := range []string{
+		"22 1 0:21 / /proc rw proc proc rw",
+		"x 1 0:21 / /proc rw - proc proc rw",
+		"22 1 0-21 / /proc rw - proc proc rw",
+		"22 1 0:21 / /proc rw -",
+	} {
+		_, err := Parse(strings.NewReader(testTable + line + "\n"))
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("%q: got %v; want *SyntaxError", line, err)
+		} else if se.Line != 6 {
+			t.Errorf("%q: got line %d; want 6", line, se.Line)
+		}
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "mountinfo")
+	if err := os.WriteFile(name, []byte(testTable), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mounts, err := ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := NewTable(mounts)
+	if table.Len() != 4 {
+		t.Errorf("got %d mount points; want 4", table.Len())
+	}
+	if m := table.Lookup("/home/u/remote"); m == nil || m.FSType != "tmpfs" {
+		t.Errorf("got %+v; want the tmpfs on top", m)
+	}
+	if m := table.Lookup("/home/u"); m != nil {
+		t.Errorf("got %+v; want nil", m)
+	}
+}
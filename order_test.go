@@ -0,0 +1,85 @@
+package gotfp
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// testCollectDepths traverses root with t and returns the depths
+// passed to the file handler, in the order the handler is called.
+func testCollectDepths(tb testing.TB, t *Traverser, root string) []int {
+	tb.Helper()
+	var mu sync.Mutex
+	var depths []int
+	err := t.Files(context.Background(), func(info FileInfo, depth int) Action {
+		mu.Lock()
+		depths = append(depths, depth)
+		mu.Unlock()
+		return ActionContinue
+	}, root)
+	if err != nil {
+		tb.Error(err)
+	}
+	return depths
+}
+
+func TestWithTraversalOrder(t *testing.T) {
+	root := testMakeTree(t, "a/b/c/d.txt", "a/b/e.txt", "a/f.txt",
+		"g/h/i.txt", "g/j.txt", "k.txt")
+	testCases := []struct {
+		order     TraversalOrder
+		workers   int
+		chunkSize int
+	}{
+		{BreadthFirst, 1, 0},
+		{LevelSync, 1, 0},
+		{LevelSync, 4, 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.order.String(), func(t *testing.T) {
+			depths := testCollectDepths(t, NewTraverser(
+				WithWorkerNumber(tc.workers), WithTraversalOrder(tc.order),
+				WithChunkSize(tc.chunkSize)), root)
+			if len(depths) != 12 {
+				t.Errorf("got %d files; want 12", len(depths))
+			}
+			for i := 1; i < len(depths); i++ {
+				if depths[i] < depths[i-1] {
+					t.Errorf("got depths %v; want non-decreasing", depths)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestTraversalOrder_Exit(t *testing.T) {
+	root := testMakeTree(t, "a/b/c.txt", "d/e.txt")
+	depths := testCollectDepths(t, NewTraverser(
+		WithTraversalOrder(LevelSync)), root)
+	if len(depths) != 6 {
+		t.Fatalf("got %d files; want 6", len(depths))
+	}
+	var n int
+	err := NewTraverser(WithTraversalOrder(LevelSync)).Files(
+		context.Background(), func(info FileInfo, depth int) Action {
+			n++
+			return ActionExit
+		}, root)
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 1 {
+		t.Errorf("handler called %d times after exit; want 1", n)
+	}
+}
+
+func TestWithTraversalOrder_Unknown(t *testing.T) {
+	defer func() {
+		if _, ok := recover().(*UnknownTraversalOrderError); !ok {
+			t.Error("no UnknownTraversalOrderError panic")
+		}
+	}()
+	NewTraverser(WithTraversalOrder(TraversalOrder(100)))
+}
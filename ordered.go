@@ -0,0 +1,414 @@
+package gotfp
+
+import (
+	"container/heap"
+	"context"
+	"io/fs"
+	"sort"
+	"sync"
+	"time"
+)
+
+// deliverInOrder runs handler on the tasks of the traversal one at a time,
+// in the calling goroutine, in lexical pre-order: a task, and then its
+// new tasks sorted by path, each followed by all tasks derived from it.
+// Meanwhile, tr.Prefetch reads the directories and stats their entries
+// ahead of the delivery.
+//
+// Ensure ctx != nil && handler != nil && tr != nil && tr.Prefetch != nil
+// && len(roots) > 0.
+// It returns ctx.Err() if the traversal is stopped by ctx, otherwise nil.
+func deliverInOrder(ctx context.Context,
+	handler taskHandler,
+	tr *tTraversal,
+	roots ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tasks := make([]*tTask, 0, len(roots))
+	for i := range roots {
+		root := tr.FS.Root(roots[i])
+		tasks = append(tasks, &tTask{
+			FileInfo: FileInfo{Path: root},
+			Depth:    0,
+			Root:     root,
+		})
+		if tr.reads(0) {
+			tr.Prefetch.add(nil, i, root, root)
+		}
+	}
+	tr.Prefetch.start(int(tr.Cfg.workerSettings.Number))
+	defer tr.Prefetch.stop()
+	var isCancelled bool
+	var run func(task *tTask) (doesExit bool)
+	run = func(task *tTask) (doesExit bool) {
+		// Don't deliver any more task after ctx is done.
+		if ctx.Err() != nil {
+			isCancelled = true
+			return true
+		}
+		var errBuf []error
+		newTasks, doesExit := handler(task, &errBuf)
+		for _, err := range errBuf {
+			tr.Errs.add(task.FileInfo.Path, err)
+			tr.sendWorkerErr(err)
+		}
+		if doesExit {
+			return true
+		}
+		sort.SliceStable(newTasks, func(i, j int) bool {
+			return newTasks[i].FileInfo.Path < newTasks[j].FileInfo.Path
+		})
+		for _, newTask := range newTasks {
+			newTask.Depth = task.Depth + 1
+			if run(newTask) {
+				return true
+			}
+		}
+		// The whole subtree has been delivered.
+		tr.Prefetch.finish(task.FileInfo.Path)
+		return false
+	}
+	for _, task := range tasks {
+		if run(task) {
+			break
+		}
+	}
+	if isCancelled {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// sendWorkerErr sends err to tr.Cfg.workerErrChan, as the workers do.
+func (tr *tTraversal) sendWorkerErr(err error) {
+	errChan := tr.Cfg.workerErrChan
+	if errChan == nil {
+		return
+	}
+	if timeout := tr.Cfg.workerSettings.SendErrTimeout; timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case errChan <- err:
+		case <-timer.C:
+		}
+		return
+	}
+	select {
+	case errChan <- err:
+	default:
+	}
+}
+
+// tStatEntry is a directory entry with its info loaded in advance.
+type tStatEntry struct {
+	fs.DirEntry
+	info fs.FileInfo
+	err  error
+}
+
+func (se *tStatEntry) Info() (fs.FileInfo, error) {
+	return se.info, se.err
+}
+
+// tPrefetchDir is a directory to read ahead of the delivery.
+type tPrefetchDir struct {
+	Path     string
+	Root     string
+	Key      []int // Position in the pre-order: root index, then child indices.
+	Parent   *tPrefetchDir
+	Children []*tPrefetchDir // Sub-directories, set when read.
+	Entries  []fs.DirEntry   // Set when read successfully.
+	RootDev  uint64          // Device number of the root, to stay on its file system.
+	HasDev   bool            // Whether RootDev is known.
+	Ignore   *tIgnoreStack   // Gitignore rules for its children, set when read.
+
+	index  int  // Index in the queue, or -1 if not in the queue.
+	isRead bool // Whether it has been read, successfully or not.
+	isOK   bool // Whether it has been read successfully.
+	isDead bool // Whether it is no longer needed.
+	done   chan struct{}
+}
+
+// tPrefetchHeap is a priority queue of directories
+// in the order of tPrefetchDir.Key.
+type tPrefetchHeap []*tPrefetchDir
+
+func (h tPrefetchHeap) Len() int {
+	return len(h)
+}
+
+func (h tPrefetchHeap) Less(i, j int) bool {
+	a, b := h[i].Key, h[j].Key
+	for k := 0; k < len(a) && k < len(b); k++ {
+		if a[k] != b[k] {
+			return a[k] < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+func (h tPrefetchHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *tPrefetchHeap) Push(x interface{}) {
+	d := x.(*tPrefetchDir)
+	d.index = len(*h)
+	*h = append(*h, d)
+}
+
+func (h *tPrefetchHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	x.index = -1
+	return x
+}
+
+// tPrefetcher reads directories ahead of the delivery in order,
+// in the order they are delivered, holding at most about MaxBuffered
+// entries of directories whose subtrees have not been delivered.
+// The directory that the delivery waits for is always read,
+// even if the limit is reached, so that the delivery can progress.
+type tPrefetcher struct {
+	Tr          *tTraversal
+	MaxBuffered int
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    tPrefetchHeap
+	dirs     map[string]*tPrefetchDir // Directories not finished, by path.
+	awaited  *tPrefetchDir
+	buffered int
+	isClosed bool
+	wg       sync.WaitGroup
+}
+
+func newPrefetcher(tr *tTraversal, maxBuffered int) *tPrefetcher {
+	p := &tPrefetcher{
+		Tr:          tr,
+		MaxBuffered: maxBuffered,
+		dirs:        make(map[string]*tPrefetchDir),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// add queues the directory at path, the idx-th child of parent
+// (or the idx-th root if parent is nil), to be read.
+// It returns the directory added.
+// Ensure p.mu is locked if the workers have been started.
+func (p *tPrefetcher) add(parent *tPrefetchDir, idx int,
+	path, root string) *tPrefetchDir {
+	d := &tPrefetchDir{
+		Path:   path,
+		Root:   root,
+		Parent: parent,
+		done:   make(chan struct{}),
+	}
+	if parent != nil {
+		d.Key = append(append(make([]int, 0, len(parent.Key)+1),
+			parent.Key...), idx)
+		parent.Children = append(parent.Children, d)
+		d.RootDev, d.HasDev = parent.RootDev, parent.HasDev
+	} else {
+		d.Key = []int{idx}
+	}
+	p.dirs[path] = d
+	heap.Push(&p.queue, d)
+	return d
+}
+
+func (p *tPrefetcher) start(numWorkers int) {
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	p.wg.Add(numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		go p.work()
+	}
+}
+
+// stop stops the workers and waits for them to exit.
+func (p *tPrefetcher) stop() {
+	p.mu.Lock()
+	p.isClosed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+func (p *tPrefetcher) work() {
+	defer p.wg.Done()
+	for {
+		p.mu.Lock()
+		for !p.isClosed && !p.canPop() {
+			p.cond.Wait()
+		}
+		if p.isClosed {
+			p.mu.Unlock()
+			return
+		}
+		var d *tPrefetchDir
+		if p.awaited != nil && p.awaited.index >= 0 {
+			d = heap.Remove(&p.queue, p.awaited.index).(*tPrefetchDir)
+		} else {
+			d = heap.Pop(&p.queue).(*tPrefetchDir)
+		}
+		isDead := d.isDead
+		p.mu.Unlock()
+		var entries []fs.DirEntry
+		var err error
+		if !isDead {
+			entries, err = p.read(d)
+		}
+		p.mu.Lock()
+		d.isRead = true
+		if !d.isDead && err == nil {
+			d.Entries, d.isOK = entries, true
+			p.buffered += len(entries)
+			// The depth of d is len(d.Key)-1.
+			isDescended := p.Tr.reads(len(d.Key))
+			for i, entry := range entries {
+				if !isDescended || !entry.IsDir() {
+					continue
+				}
+				path := p.Tr.FS.Join(d.Path, entry.Name())
+				if p.Tr.Cfg.filter.excludes(d.Root, path, true) ||
+					p.Tr.Cfg.gitignore && (entry.Name() == gitDirName ||
+						d.Ignore.ignores(path, true)) ||
+					d.HasDev && !p.isOnDev(entry, d.RootDev) ||
+					p.Tr.excludesMount(p.Tr.Mounts.Lookup(path)) {
+					continue
+				}
+				p.add(d, i, path, d.Root)
+			}
+		}
+		close(d.done)
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	}
+}
+
+// canPop reports whether a directory in the queue can be read:
+// the one that the delivery waits for, or the next one if it is dead
+// or the limit is not reached.
+// Ensure p.mu is locked.
+func (p *tPrefetcher) canPop() bool {
+	if len(p.queue) == 0 {
+		return false
+	}
+	return p.awaited != nil && p.awaited.index >= 0 ||
+		p.queue[0].isDead || p.buffered < p.MaxBuffered
+}
+
+// isOnDev reports whether the file of entry is on the device dev.
+func (p *tPrefetcher) isOnDev(entry fs.DirEntry, dev uint64) bool {
+	info, err := entry.Info()
+	if err != nil {
+		return false
+	}
+	d, ok := getDeviceID(info)
+	return !ok || d == dev
+}
+
+// read reads the entries of d sorted by name, and loads their info.
+// It also records the device number of a root to stay on its file system,
+// and loads the gitignore rules for the children of d if needed,
+// so that ignored directories are not read ahead.
+// Errors in loading the rules are dropped here, as they are reported
+// when d is delivered.
+func (p *tPrefetcher) read(d *tPrefetchDir) ([]fs.DirEntry, error) {
+	if d.Parent == nil && p.Tr.Cfg.sameFileSystem {
+		if info, err := p.Tr.FS.Lstat(d.Path); err == nil {
+			d.RootDev, d.HasDev = getDeviceID(info)
+		}
+	}
+	entries, err := p.Tr.FS.ReadDir(d.Path, true)
+	if err != nil {
+		return nil, err
+	}
+	var hasGitDir, hasGitignore bool
+	for i := range entries {
+		switch entries[i].Name() {
+		case gitDirName:
+			hasGitDir = true
+		case gitignoreName:
+			hasGitignore = true
+		}
+		info, err := entries[i].Info()
+		entries[i] = &tStatEntry{DirEntry: entries[i], info: info, err: err}
+	}
+	if p.Tr.Cfg.gitignore {
+		var s *tIgnoreStack
+		var errs []error
+		if d.Parent != nil {
+			s = d.Parent.Ignore
+		} else {
+			s = p.Tr.rootIgnore(d.Path, &errs)
+		}
+		d.Ignore = p.Tr.pushDirIgnore(s, d.Path, hasGitDir, hasGitignore, &errs)
+	}
+	return entries, nil
+}
+
+// take returns the entries of the directory at path read in advance,
+// waiting for it if it is being read or in the queue.
+// It returns false if the directory is not read in advance,
+// or cannot be read. Then the caller should read it by itself.
+// A nil p returns false.
+func (p *tPrefetcher) take(path string) (entries []fs.DirEntry, ok bool) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	d := p.dirs[path]
+	if d == nil || d.isDead {
+		p.mu.Unlock()
+		return
+	}
+	if !d.isRead {
+		p.awaited = d
+		p.cond.Broadcast()
+		p.mu.Unlock()
+		<-d.done
+		p.mu.Lock()
+	}
+	entries, ok = d.Entries, d.isOK && !d.isDead
+	p.mu.Unlock()
+	return
+}
+
+// finish marks the directory at path and all directories in it
+// as no longer needed, after its subtree has been delivered.
+func (p *tPrefetcher) finish(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	d := p.dirs[path]
+	if d == nil {
+		return
+	}
+	p.kill(d)
+	p.cond.Broadcast()
+}
+
+// kill marks d and its descendants dead, and releases their entries.
+// Ensure p.mu is locked.
+func (p *tPrefetcher) kill(d *tPrefetchDir) {
+	if d.isDead {
+		return
+	}
+	d.isDead = true
+	p.buffered -= len(d.Entries)
+	d.Entries, d.isOK = nil, false
+	delete(p.dirs, d.Path)
+	for _, child := range d.Children {
+		p.kill(child)
+	}
+	d.Children = nil
+}
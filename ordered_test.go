@@ -0,0 +1,167 @@
+package gotfp
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// testMakeOrderedTree creates a tree with some wide and deep directories.
+func testMakeOrderedTree(tb testing.TB) string {
+	tb.Helper()
+	var paths []string
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 4; j++ {
+			paths = append(paths, fmt.Sprintf("d%d/e%d/f.txt", i, j))
+		}
+		paths = append(paths, fmt.Sprintf("d%d.txt", i), fmt.Sprintf("d%d/g/", i))
+	}
+	paths = append(paths, "a b/c.txt", "a-b/c.txt", "a/b/c/d/e.txt")
+	return testMakeTree(tb, paths...)
+}
+
+// testWalkDir returns the paths visited by filepath.WalkDir,
+// skipping the directories with a name in skip.
+func testWalkDir(tb testing.TB, root string, skip string) []string {
+	tb.Helper()
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+		if d.IsDir() && d.Name() == skip {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return paths
+}
+
+func TestWithOrderedDelivery_Files(t *testing.T) {
+	root := testMakeOrderedTree(t)
+	for _, skip := range []string{"", "e1"} {
+		want := testWalkDir(t, root, skip)
+		for _, maxBuffered := range []int{1, 8, 1000} {
+			t.Run(fmt.Sprintf("skip=%q,buf=%d", skip, maxBuffered), func(t *testing.T) {
+				var got []string
+				err := NewTraverser(WithWorkerNumber(4),
+					WithOrderedDelivery(maxBuffered)).Files(
+					context.Background(), func(info FileInfo, depth int) Action {
+						got = append(got, info.Path) // No lock needed.
+						if info.Cat == Directory && filepath.Base(info.Path) == skip {
+							return ActionSkip
+						}
+						return ActionContinue
+					}, root)
+				if err != nil {
+					t.Error(err)
+				}
+				if strings.Join(got, "\n") != strings.Join(want, "\n") {
+					t.Errorf("got %q;\nwant %q", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestWithOrderedDelivery_Batches(t *testing.T) {
+	root := testMakeOrderedTree(t)
+	var want []string
+	for _, p := range testWalkDir(t, root, "") {
+		if GetFileInfo(p).Cat == Directory {
+			want = append(want, p)
+		}
+	}
+	var got []string
+	err := NewTraverser(WithWorkerNumber(4), WithOrderedDelivery(4)).Batches(
+		context.Background(), func(batch Batch, depth int) (Action, map[string]bool) {
+			got = append(got, batch.Parent.Path)
+			return ActionContinue, nil
+		}, root)
+	if err != nil {
+		t.Error(err)
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q;\nwant %q", got, want)
+	}
+}
+
+func TestWithOrderedDelivery_Exit(t *testing.T) {
+	root := testMakeOrderedTree(t)
+	want := testWalkDir(t, root, "")[:10]
+	var got []string
+	err := NewTraverser(WithWorkerNumber(4), WithOrderedDelivery(4)).Files(
+		context.Background(), func(info FileInfo, depth int) Action {
+			got = append(got, info.Path)
+			if len(got) == len(want) {
+				return ActionExit
+			}
+			return ActionContinue
+		}, root)
+	if err != nil {
+		t.Error(err)
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q;\nwant %q", got, want)
+	}
+}
+
+// testCountingFS is an fstest.MapFS that counts ReadDir calls by name.
+type testCountingFS struct {
+	fstest.MapFS
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (fsys *testCountingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	fsys.mu.Lock()
+	fsys.counts[name]++
+	fsys.mu.Unlock()
+	return fsys.MapFS.ReadDir(name)
+}
+
+func TestWithOrderedDelivery_Gitignore(t *testing.T) {
+	fsys := &testCountingFS{
+		MapFS: fstest.MapFS{
+			".gitignore":       {Data: []byte("ignored/\n")},
+			"ignored/a/b.txt":  {Data: []byte("b")},
+			"kept/c.txt":       {Data: []byte("c")},
+			"kept/.gitignore":  {Data: []byte("d/\n")},
+			"kept/d/e/f.txt":   {Data: []byte("f")},
+			"kept/g/ignored/h": {Data: []byte("h")},
+		},
+		counts: make(map[string]int),
+	}
+	var got []string
+	err := NewTraverser(WithFS(fsys), WithGitignore(true),
+		WithGitExcludesFile(""), WithWorkerNumber(4),
+		WithOrderedDelivery(100)).Files(context.Background(),
+		func(info FileInfo, depth int) Action {
+			got = append(got, info.Path)
+			return ActionContinue
+		}, ".")
+	if err != nil {
+		t.Error(err)
+	}
+	want := []string{".", ".gitignore", "kept", "kept/.gitignore",
+		"kept/c.txt", "kept/g"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	for _, name := range []string{"ignored", "ignored/a", "kept/d",
+		"kept/d/e", "kept/g/ignored"} {
+		if n := fsys.counts[name]; n != 0 {
+			t.Errorf("%q: read %d times; want 0", name, n)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package gotfp
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ReduceResult is the result of Reduce.
+type ReduceResult[T any] struct {
+	// Roots are the values of roots, by their paths in FileInfo.Path.
+	Roots map[string]T
+	// Dirs are the values of directories descended into, including roots,
+	// by their paths in FileInfo.Path.
+	Dirs map[string]T
+}
+
+// tReduceValue wraps a value in Reduce,
+// to tell it from a directory without value, which is nil.
+type tReduceValue[T any] struct {
+	V T
+}
+
+// Reduce traverses roots with t, maps each file to a value by mapFn,
+// and combines the values bottom-up by combine.
+//
+// The value of a directory descended into is its own value combined with
+// those of its children, from left to right, in the order that its
+// children are traversed (see WithSortOrder; by name only with SortByName).
+// combine should be associative, as the values of directories are
+// combined by the worker handling the last of their descendants.
+//
+// The value of a directory not descended into, e.g., at the maximum depth
+// (see WithMaxDepth) or on another file system (see WithSameFileSystem),
+// is its own value.
+//
+// The action returned by mapFn works as in FileHandler: the value of
+// a skipped directory is its own value, and once mapFn returns ActionExit,
+// no more values are combined, so directories left unfinished
+// have no results. Files not included (see WithInclude) have no value.
+//
+// Files above the minimum depth (see WithMinDepth) are not mapped,
+// and their directories are not combined, so roots and the other
+// directories above the minimum depth are absent from Roots and Dirs.
+//
+// The result is returned even if an error occurs.
+// The error is the same as the one returned by Traverser.Files.
+func Reduce[T any](ctx context.Context, t *Traverser,
+	mapFn func(info FileInfo, depth int) (T, Action),
+	combine func(x, y T) T,
+	roots ...string) (*ReduceResult[T], error) {
+	if t == nil {
+		panic(errors.New("gotfp: traverser is nil"))
+	}
+	if mapFn == nil {
+		panic(errors.New("gotfp: map function is nil"))
+	}
+	if combine == nil {
+		panic(errors.New("gotfp: combine function is nil"))
+	}
+	result := &ReduceResult[T]{
+		Roots: make(map[string]T),
+		Dirs:  make(map[string]T),
+	}
+	var mu sync.Mutex
+	var own sync.Map // Own values of directories being descended into.
+	err := t.FilesWithLeave(ctx,
+		func(info FileInfo, depth int) (Action, interface{}) {
+			value, action := mapFn(info, depth)
+			switch {
+			case action == ActionExit:
+				// Do nothing here.
+			case action != ActionSkip && t.descends(info, depth):
+				// Combined with its children when left.
+				own.Store(info.Path, tReduceValue[T]{V: value})
+			case depth == 0:
+				mu.Lock()
+				result.Roots[info.Path] = value
+				mu.Unlock()
+			}
+			return action, tReduceValue[T]{V: value}
+		},
+		func(dir FileInfo, depth int, childValues []interface{}) interface{} {
+			var value T
+			ok := false
+			if v, loaded := own.LoadAndDelete(dir.Path); loaded {
+				value, ok = v.(tReduceValue[T]).V, true
+			}
+			for _, v := range childValues {
+				x, isValue := v.(tReduceValue[T])
+				if !isValue {
+					continue
+				}
+				if ok {
+					value = combine(value, x.V)
+				} else {
+					value, ok = x.V, true
+				}
+			}
+			if !ok {
+				return nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			result.Dirs[dir.Path] = value
+			if depth == 0 {
+				result.Roots[dir.Path] = value
+			}
+			return tReduceValue[T]{V: value}
+		}, roots...)
+	return result, err
+}
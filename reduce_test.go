@@ -0,0 +1,101 @@
+package gotfp
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestReduce(t *testing.T) {
+	root := testMakeTree(t, "a/b/c.txt", "a/d.txt", "e/", "f.txt",
+		"skip/g.txt", "skip/h/i.txt")
+	file := testMakeTree(t, "j.txt")
+	countFiles := func(info FileInfo, depth int) (int, Action) {
+		if filepath.Base(info.Path) == "skip" {
+			return 100, ActionSkip
+		}
+		if info.Cat == RegularFile {
+			return 1, ActionContinue
+		}
+		return 0, ActionContinue
+	}
+	sum := func(x, y int) int {
+		return x + y
+	}
+	jPath := filepath.Join(file, "j.txt")
+	for _, workers := range []int{1, 4} {
+		result, err := Reduce(context.Background(),
+			NewTraverser(WithWorkerNumber(workers)),
+			countFiles, sum, root, jPath)
+		if err != nil {
+			t.Error(err)
+		}
+		wantRoots := map[string]int{root: 103, jPath: 1}
+		if len(result.Roots) != len(wantRoots) {
+			t.Errorf("got roots %v; want %v", result.Roots, wantRoots)
+		}
+		for k, v := range wantRoots {
+			if result.Roots[k] != v {
+				t.Errorf("root %q: got %d; want %d", k, result.Roots[k], v)
+			}
+		}
+		wantDirs := map[string]int{
+			root:                       103,
+			filepath.Join(root, "a"):   2,
+			filepath.Join(root, "a/b"): 1,
+			filepath.Join(root, "e"):   0,
+		}
+		if len(result.Dirs) != len(wantDirs) {
+			t.Errorf("got dirs %v; want %v", result.Dirs, wantDirs)
+		}
+		for k, v := range wantDirs {
+			if result.Dirs[k] != v {
+				t.Errorf("dir %q: got %d; want %d", k, result.Dirs[k], v)
+			}
+		}
+	}
+}
+
+func TestReduce_Exit(t *testing.T) {
+	root := testMakeTree(t, "a/b.txt", "c.txt")
+	result, err := Reduce(context.Background(), NewTraverser(),
+		func(info FileInfo, depth int) (int, Action) {
+			if info.Cat == RegularFile {
+				return 1, ActionExit
+			}
+			return 0, ActionContinue
+		}, func(x, y int) int {
+			return x + y
+		}, root)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(result.Roots) != 0 {
+		t.Errorf("got roots %v; want none", result.Roots)
+	}
+}
+
+func TestReduce_MinDepth(t *testing.T) {
+	root := testMakeTree(t, "a/b/c.txt", "a/d.txt", "e.txt")
+	result, err := Reduce(context.Background(), NewTraverser(WithMinDepth(2)),
+		func(info FileInfo, depth int) (int, Action) {
+			return 1, ActionContinue
+		}, func(x, y int) int {
+			return x + y
+		}, root)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(result.Roots) != 0 {
+		t.Errorf("got roots %v; want none", result.Roots)
+	}
+	want := map[string]int{filepath.Join(root, "a/b"): 2}
+	if len(result.Dirs) != len(want) {
+		t.Errorf("got dirs %v; want %v", result.Dirs, want)
+	}
+	for k, v := range want {
+		if result.Dirs[k] != v {
+			t.Errorf("dir %q: got %d; want %d", k, result.Dirs[k], v)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+//go:build unix
+
+package gotfp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWithSameFileSystem_MountPoint(t *testing.T) {
+	root := testMakeTree(t, "a/b.txt", "c.txt")
+	cfg := NewTraverser(WithSameFileSystem(true)).cfg
+	tr := newTraversal(&cfg)
+	parent := &tTask{Root: root}
+	tr.initRoot(&tTask{FileInfo: FileInfo{Path: root}, Root: root}, nil)
+	if info, _ := getFileInfo(filepath.Join(root, "a"), nil, tr, parent, true); info.MountPoint {
+		t.Error("a is a mount point on the same device")
+	}
+	// Pretend that the root is on another device.
+	tr.RootDevs.Store(root, ^uint64(0))
+	if info, _ := getFileInfo(filepath.Join(root, "a"), nil, tr, parent, true); !info.MountPoint {
+		t.Error("a is not a mount point on another device")
+	}
+	if info, _ := getFileInfo(filepath.Join(root, "c.txt"), nil, tr, parent, true); info.MountPoint {
+		t.Error("regular file c.txt is a mount point")
+	}
+}
+
+func TestWithSameFileSystem_Proc(t *testing.T) {
+	rootInfo, err1 := os.Stat("/")
+	procInfo, err2 := os.Stat("/proc")
+	if err1 != nil || err2 != nil {
+		t.Skip("no /proc")
+	}
+	rootDev, _ := getDeviceID(rootInfo)
+	procDev, _ := getDeviceID(procInfo)
+	if rootDev == procDev {
+		t.Skip("/proc is on the same device as /")
+	}
+	for _, ordered := range []int{0, 100} {
+		var mu sync.Mutex
+		var isMountPoint bool
+		err := NewTraverser(WithSameFileSystem(true), WithMaxDepth(2),
+			WithOrderedDelivery(ordered), WithInclude("proc", "proc/*")).Files(
+			context.Background(), func(info FileInfo, depth int) Action {
+				mu.Lock()
+				defer mu.Unlock()
+				if info.Path == "/proc" {
+					isMountPoint = info.MountPoint
+				} else if strings.HasPrefix(info.Path, "/proc/") {
+					t.Errorf("%q on another file system is visited", info.Path)
+				}
+				return ActionContinue
+			}, "/")
+		_ = err // Some directories may be unreadable.
+		if !isMountPoint {
+			t.Error("/proc is not reported as a mount point")
+		}
+	}
+}
+
+func TestReduce_MountPoint(t *testing.T) {
+	rootInfo, err1 := os.Stat("/")
+	procInfo, err2 := os.Stat("/proc")
+	if err1 != nil || err2 != nil {
+		t.Skip("no /proc")
+	}
+	rootDev, _ := getDeviceID(rootInfo)
+	procDev, _ := getDeviceID(procInfo)
+	if rootDev == procDev {
+		t.Skip("/proc is on the same device as /")
+	}
+	result, _ := Reduce(context.Background(), // Some directories may be unreadable.
+		NewTraverser(WithSameFileSystem(true), WithMaxDepth(2),
+			WithInclude("proc")),
+		func(info FileInfo, depth int) (int, Action) {
+			if info.Path == "/proc" {
+				if !info.MountPoint {
+					t.Error("/proc is not reported as a mount point")
+				}
+				return 1, ActionContinue
+			}
+			return 0, ActionContinue
+		}, func(x, y int) int {
+			return x + y
+		}, "/")
+	if _, ok := result.Dirs["/proc"]; ok {
+		t.Error("mount point /proc is in Dirs")
+	}
+	if result.Roots["/"] != 1 {
+		t.Errorf("got %d for /; want 1", result.Roots["/"])
+	}
+}
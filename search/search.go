@@ -0,0 +1,293 @@
+// Package search searches the contents of files with gotfp, like grep.
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"regexp"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/donyori/gotfp"
+)
+
+// binaryPeekSize is the number of bytes from the beginning of a file
+// looked for a NUL byte, to tell whether it is binary, as GNU grep.
+const binaryPeekSize = 8000
+
+// DefaultMaxLineSize is the default maximum size of a line, in bytes,
+// including the line ending.
+const DefaultMaxLineSize = 1 << 20
+
+// ctxCheckSize is the number of bytes read between checks of the context.
+const ctxCheckSize = 1 << 20
+
+// Match is a line matching the pattern.
+type Match struct {
+	Path   string
+	Line   int    // Line number, from 1.
+	Offset int64  // Byte offset of the first match in the line, from the beginning of the file.
+	Text   string // The line, without the line ending.
+}
+
+type config struct {
+	traverserOpts []gotfp.Option
+	isLiteral     bool
+	ignoreCase    bool
+	searchBinary  bool
+	maxPerFile    int
+	maxLineSize   int
+	firstOnly     bool
+}
+
+// Searcher searches regular files for a pattern.
+// It can be reused, also concurrently.
+type Searcher struct {
+	cfg config
+	re  *regexp.Regexp
+	t   *gotfp.Traverser // Finds and opens files.
+}
+
+// Option configures a Searcher.
+type Option func(cfg *config)
+
+// New creates a Searcher for pattern, a regular expression in the syntax
+// of package regexp, or a literal string with WithLiteral.
+// Patterns are matched against each line, without the line ending.
+//
+// By default, it skips binary files (those with a NUL byte in their first
+// 8000 bytes), reports all matching lines, allows lines of
+// DefaultMaxLineSize bytes, and uses the default options
+// of gotfp.NewTraverser.
+//
+// It returns an error if pattern is not a valid regular expression.
+func New(pattern string, opts ...Option) (*Searcher, error) {
+	s := &Searcher{cfg: config{maxLineSize: DefaultMaxLineSize}}
+	for _, opt := range opts {
+		opt(&s.cfg)
+	}
+	if s.cfg.isLiteral {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if s.cfg.ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	var err error
+	if s.re, err = regexp.Compile(pattern); err != nil {
+		return nil, err
+	}
+	s.t = gotfp.NewTraverser(s.cfg.traverserOpts...)
+	return s, nil
+}
+
+// WithLiteral sets whether the pattern is a literal string.
+func WithLiteral(literal bool) Option {
+	return func(cfg *config) {
+		cfg.isLiteral = literal
+	}
+}
+
+// WithIgnoreCase sets whether to match case-insensitively.
+func WithIgnoreCase(ignoreCase bool) Option {
+	return func(cfg *config) {
+		cfg.ignoreCase = ignoreCase
+	}
+}
+
+// WithBinaryFiles sets whether to search binary files as well.
+func WithBinaryFiles(search bool) Option {
+	return func(cfg *config) {
+		cfg.searchBinary = search
+	}
+}
+
+// WithMaxMatches sets the maximum number of matching lines reported
+// for each file. The rest of a file is not read once it is reached.
+// Non-positive n stands for no limit, which is the default.
+func WithMaxMatches(n int) Option {
+	return func(cfg *config) {
+		cfg.maxPerFile = n
+	}
+}
+
+// WithMaxLineSize sets the maximum size of a line, in bytes,
+// including the line ending. A file with a longer line is searched
+// up to that line, and reported with an error wrapping bufio.ErrTooLong.
+// Non-positive n stands for DefaultMaxLineSize.
+func WithMaxLineSize(n int) Option {
+	return func(cfg *config) {
+		if n <= 0 {
+			n = DefaultMaxLineSize
+		}
+		cfg.maxLineSize = n
+	}
+}
+
+// WithFirstMatch sets whether to stop at the first matching line.
+// If first is true, the traversal exits (see gotfp.ActionExit)
+// once any worker finds a match, and only that match is reported.
+func WithFirstMatch(first bool) Option {
+	return func(cfg *config) {
+		cfg.firstOnly = first
+	}
+}
+
+// WithTraverserOptions adds options of the Traverser to find files,
+// e.g., gotfp.WithInclude and gotfp.WithGitignore.
+func WithTraverserOptions(opts ...gotfp.Option) Option {
+	return func(cfg *config) {
+		cfg.traverserOpts = append(cfg.traverserOpts, opts...)
+	}
+}
+
+// Search searches the regular files in roots,
+// and returns the matches sorted by path and line number.
+//
+// The matches are returned even if an error occurs.
+// The error joins the one returned by gotfp.Traverser.Files and
+// those of reading files. Files being searched when ctx is done
+// are left unfinished.
+func (s *Searcher) Search(ctx context.Context, roots ...string) (
+	[]*Match, error) {
+	var mu sync.Mutex
+	var matches []*Match
+	var errs []error
+	var isFound atomic.Bool
+	err := s.t.Files(ctx,
+		func(info gotfp.FileInfo, depth int) gotfp.Action {
+			if info.Cat != gotfp.RegularFile {
+				return gotfp.ActionContinue
+			}
+			if s.cfg.firstOnly && isFound.Load() {
+				return gotfp.ActionExit
+			}
+			ms, err := s.SearchFile(ctx, info.Path)
+			mu.Lock()
+			defer mu.Unlock()
+			// Cancellation is reported by the traversal.
+			if err != nil && ctx.Err() == nil {
+				errs = append(errs, err)
+			}
+			if len(ms) == 0 {
+				return gotfp.ActionContinue
+			}
+			if !s.cfg.firstOnly {
+				matches = append(matches, ms...)
+				return gotfp.ActionContinue
+			}
+			// Only the first worker finding a match reports it.
+			if !isFound.Swap(true) {
+				matches = append(matches, ms[0])
+			}
+			return gotfp.ActionExit
+		}, roots...)
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Path != matches[j].Path {
+			return matches[i].Path < matches[j].Path
+		}
+		return matches[i].Line < matches[j].Line
+	})
+	return matches, errors.Join(append([]error{err}, errs...)...)
+}
+
+// SearchFile searches the file name, and returns the matches
+// in the order of lines. It returns no match for a binary file,
+// unless WithBinaryFiles(true).
+//
+// name is a path in the file system set by gotfp.WithFS in
+// WithTraverserOptions, if any, as Search reads files through
+// gotfp.Traverser.Open.
+//
+// It stops reading the file once ctx is done, and returns the matches
+// found so far with ctx.Err().
+func (s *Searcher) SearchFile(ctx context.Context, name string) (
+	[]*Match, error) {
+	f, err := s.t.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // Ignore error.
+	matches, err := s.searchReader(ctx, name, f)
+	if err != nil && err != ctx.Err() {
+		err = &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return matches, err
+}
+
+// searchReader searches r, the contents of the file name.
+func (s *Searcher) searchReader(ctx context.Context, name string,
+	r io.Reader) ([]*Match, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	if !s.cfg.searchBinary {
+		head, err := br.Peek(binaryPeekSize)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if bytes.IndexByte(head, 0) >= 0 {
+			return nil, nil
+		}
+	}
+	limit := s.cfg.maxPerFile
+	if s.cfg.firstOnly {
+		limit = 1
+	}
+	sc := bufio.NewScanner(br)
+	sc.Buffer(make([]byte, 0, min(64*1024, s.cfg.maxLineSize)),
+		s.cfg.maxLineSize)
+	sc.Split(scanLines)
+	var matches []*Match
+	var offset, nextCheck int64
+	lineNo := 0
+	for sc.Scan() {
+		if offset >= nextCheck {
+			if err := ctx.Err(); err != nil {
+				return matches, err
+			}
+			nextCheck = offset + ctxCheckSize
+		}
+		lineNo++
+		line := sc.Bytes()
+		text := bytes.TrimSuffix(bytes.TrimSuffix(line, []byte{'\n'}), []byte{'\r'})
+		if loc := s.re.FindIndex(text); loc != nil {
+			matches = append(matches, &Match{
+				Path:   name,
+				Line:   lineNo,
+				Offset: offset + int64(loc[0]),
+				Text:   string(text),
+			})
+			if limit > 0 && len(matches) >= limit {
+				return matches, nil
+			}
+		}
+		offset += int64(len(line))
+	}
+	if err := sc.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			err = fmt.Errorf("line %d: %w", lineNo+1, err)
+		}
+		return matches, err
+	}
+	return matches, nil
+}
+
+// scanLines is a split function for bufio.Scanner like bufio.ScanLines,
+// but keeping the line endings, so that the byte offsets can be counted.
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	// Request more data.
+	return 0, nil, nil
+}
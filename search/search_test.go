@@ -0,0 +1,172 @@
+package search
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/donyori/gotfp"
+	"github.com/donyori/gotfp/internal/testfs"
+)
+
+// testFormat formats matches as "<relative path>:<line>:<offset>:<text>",
+// a line for each.
+func testFormat(tb testing.TB, root string, matches []*Match) string {
+	tb.Helper()
+	var b strings.Builder
+	for _, m := range matches {
+		rel, err := filepath.Rel(root, m.Path)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		fmt.Fprintf(&b, "%s:%d:%d:%s\n", filepath.ToSlash(rel), m.Line, m.Offset, m.Text)
+	}
+	return b.String()
+}
+
+var testFiles = map[string]string{
+	"a.txt":     "foo\nbar foo\r\nbaz\nfoo.bar",
+	"b/c.txt":   "nothing\nFOO\n",
+	"b/d.go":    "package d // foo",
+	"bin.dat":   "foo\x00bar",
+	"empty.txt": "",
+}
+
+func TestSearcher_Search(t *testing.T) {
+	root := testfs.MakeTree(t, testFiles)
+	testCases := []struct {
+		name    string
+		pattern string
+		opts    []Option
+		want    string
+	}{
+		{"regexp", `fo+`, nil,
+			"a.txt:1:0:foo\na.txt:2:8:bar foo\na.txt:4:17:foo.bar\nb/d.go:1:13:package d // foo\n"},
+		{"literal", "foo.", []Option{WithLiteral(true)},
+			"a.txt:4:17:foo.bar\n"},
+		{"not-literal", "foo.", nil,
+			"a.txt:4:17:foo.bar\n"},
+		{"anchor", "^foo", []Option{WithIgnoreCase(true)},
+			"a.txt:1:0:foo\na.txt:4:17:foo.bar\nb/c.txt:2:8:FOO\n"},
+		{"binary", "bar$", []Option{WithBinaryFiles(true)},
+			"a.txt:4:21:foo.bar\nbin.dat:1:4:foo\x00bar\n"},
+		{"max", "foo", []Option{WithMaxMatches(1)},
+			"a.txt:1:0:foo\nb/d.go:1:13:package d // foo\n"},
+		{"include", "foo", []Option{WithTraverserOptions(gotfp.WithInclude("**/*.go"))},
+			"b/d.go:1:13:package d // foo\n"},
+		{"none", "qux", nil, ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := New(tc.pattern, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			matches, err := s.Search(context.Background(), root)
+			if err != nil {
+				t.Error(err)
+			}
+			if got := testFormat(t, root, matches); got != tc.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSearcher_Search_FirstMatch(t *testing.T) {
+	files := make(map[string]string)
+	for i := 0; i < 200; i++ {
+		files[fmt.Sprintf("d%d/f%d.txt", i%10, i)] = "foo\nfoo\n"
+	}
+	root := testfs.MakeTree(t, files)
+	s, err := New("foo", WithFirstMatch(true),
+		WithTraverserOptions(gotfp.WithWorkerNumber(4)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, err := s.Search(context.Background(), root)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(matches) != 1 || matches[0].Line != 1 {
+		t.Errorf("got %s; want a match on line 1", testFormat(t, root, matches))
+	}
+}
+
+func TestSearcher_Search_FS(t *testing.T) {
+	fsys := make(fstest.MapFS)
+	for name, content := range testFiles {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	s, err := New("foo", WithTraverserOptions(gotfp.WithFS(fsys)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, err := s.Search(context.Background(), "b")
+	if err != nil {
+		t.Error(err)
+	}
+	if got, want := testFormat(t, ".", matches), "b/d.go:1:13:package d // foo\n"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	ms, err := s.SearchFile(context.Background(), "a.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(ms) != 3 {
+		t.Errorf("a.txt: got %d matches; want 3", len(ms))
+	}
+}
+
+func TestNew_BadPattern(t *testing.T) {
+	if _, err := New("a("); err == nil {
+		t.Error("no error for bad regexp")
+	}
+	if _, err := New("a(", WithLiteral(true)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSearcher_SearchFile_LongLine(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": {Data: []byte("foo\n" + strings.Repeat("x", 100) + "\nfoo\n")},
+	}
+	s, err := New("foo", WithMaxLineSize(64),
+		WithTraverserOptions(gotfp.WithFS(fsys)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, err := s.SearchFile(context.Background(), "a.txt")
+	if !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("got error %v; want %v", err, bufio.ErrTooLong)
+	} else if !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("got error %v; want line 2", err)
+	}
+	if len(ms) != 1 || ms[0].Line != 1 {
+		t.Errorf("got %v; want a match on line 1", ms)
+	}
+}
+
+func TestSearcher_SearchFile_Cancel(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": {Data: []byte(strings.Repeat("foo\n", 1000))},
+	}
+	s, err := New("foo", WithTraverserOptions(gotfp.WithFS(fsys)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ms, err := s.SearchFile(ctx, "a.txt")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v; want %v", err, context.Canceled)
+	}
+	if len(ms) != 0 {
+		t.Errorf("got %d matches; want 0", len(ms))
+	}
+}
@@ -0,0 +1,104 @@
+package gotfp
+
+import "sync/atomic"
+
+// tStatShard is a part of the children of a directory,
+// categorized and stat in a task of its own.
+type tStatShard struct {
+	Join   *tStatJoin
+	Lo, Hi int // Range of the children in Join.Dir.FileInfo.entries.
+}
+
+// tStatJoin gathers the results of the shards of a directory.
+type tStatJoin struct {
+	Dir      *tTask // The task of the directory, not modified by the shards.
+	Infos    []FileInfo
+	Excluded []bool
+	pending  int32 // Number of shards not done yet.
+}
+
+// statChildren categorizes the children in task.FileInfo.entries and
+// returns those not excluded, in order.
+//
+// If parallel stat is enabled and there are at least
+// tr.Cfg.parallelStatThreshold children, it returns the tasks for
+// the shards of the children instead, one for each worker.
+// The children are then returned by statShard when the last shard is done.
+//
+// Ensure tr != nil && task != nil.
+func (tr *tTraversal) statChildren(task *tTask) (
+	children []FileInfo, shards []*tTask) {
+	entries := task.FileInfo.entries
+	n := len(entries)
+	numShards := int(tr.Cfg.workerSettings.Number)
+	if tr.Cfg.parallelStatThreshold <= 0 ||
+		n < tr.Cfg.parallelStatThreshold || numShards < 2 {
+		if n == 0 {
+			return
+		}
+		children = make([]FileInfo, 0, n)
+		for _, entry := range entries {
+			fileInfo, excluded := getFileInfo(tr.FS.Join(
+				task.FileInfo.Path, entry.Name()), entry, tr, task, true)
+			if !excluded {
+				children = append(children, fileInfo)
+			}
+		}
+		return
+	}
+	if numShards > n {
+		numShards = n
+	}
+	join := &tStatJoin{
+		Dir:      task,
+		Infos:    make([]FileInfo, n),
+		Excluded: make([]bool, n),
+		pending:  int32(numShards),
+	}
+	shards = make([]*tTask, numShards)
+	size := (n + numShards - 1) / numShards
+	for i := range shards {
+		lo := i * size
+		hi := lo + size
+		if hi > n {
+			hi = n
+		}
+		shards[i] = &tTask{
+			FileInfo: task.FileInfo,
+			Root:     task.Root,
+			Ignore:   task.Ignore,
+			Shard:    &tStatShard{Join: join, Lo: lo, Hi: hi},
+		}
+	}
+	return
+}
+
+// statShard categorizes and stats the children in the shard of task.
+// If the shard is the last one done, it returns the task of the directory
+// and its children not excluded, in order. Otherwise, it returns nil.
+//
+// Ensure tr != nil && task != nil && task.Shard != nil.
+func (tr *tTraversal) statShard(task *tTask) (
+	dir *tTask, children []FileInfo) {
+	join := task.Shard.Join
+	entries := join.Dir.FileInfo.entries
+	for i := task.Shard.Lo; i < task.Shard.Hi; i++ {
+		fileInfo, excluded := getFileInfo(tr.FS.Join(
+			join.Dir.FileInfo.Path, entries[i].Name()),
+			entries[i], tr, join.Dir, true)
+		if lfi, ok := fileInfo.Info.(*tLazyFileInfo); ok {
+			lfi.load() // Stat here rather than in the handler. Ignore error.
+		}
+		join.Infos[i], join.Excluded[i] = fileInfo, excluded
+	}
+	if atomic.AddInt32(&join.pending, -1) > 0 {
+		return
+	}
+	children = make([]FileInfo, 0, len(entries))
+	for i := range join.Infos {
+		if !join.Excluded[i] {
+			children = append(children, join.Infos[i])
+		}
+	}
+	return join.Dir, children
+}
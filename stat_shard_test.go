@@ -0,0 +1,117 @@
+package gotfp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testCollectBatches traverses "wide" in testMakeWideFS(n) with t
+// and returns the children of each batch, as "parent:child,child,...".
+func testCollectBatches(tb testing.TB, t *Traverser) map[string]string {
+	tb.Helper()
+	var mu sync.Mutex
+	got := make(map[string]string)
+	err := t.Batches(context.Background(),
+		func(batch Batch, depth int) (Action, map[string]bool) {
+			var names []string
+			for _, s := range [][]FileInfo{batch.Errs, batch.RegFiles,
+				batch.Others, batch.Symlinks, batch.Dirs} {
+				for _, fi := range s {
+					names = append(names, fi.Path)
+				}
+			}
+			mu.Lock()
+			got[fmt.Sprintf("%s#%d", batch.Parent.Path, batch.ChunkIdx)] =
+				strings.Join(names, ",")
+			mu.Unlock()
+			return ActionContinue, nil
+		}, "wide")
+	if err != nil {
+		tb.Error(err)
+	}
+	return got
+}
+
+func TestTraverser_ParallelStat(t *testing.T) {
+	fsys := testMakeWideFS(50)
+	for _, chunkSize := range []int{0, 20} {
+		t.Run(fmt.Sprintf("chunk=%d", chunkSize), func(t *testing.T) {
+			want := testCollectBatches(t, NewTraverser(WithFS(fsys),
+				WithChunkSize(chunkSize)))
+			got := testCollectBatches(t, NewTraverser(WithFS(fsys),
+				WithChunkSize(chunkSize), WithWorkerNumber(4),
+				WithParallelStat(10)))
+			if len(got) != len(want) {
+				t.Errorf("got %d batches; want %d", len(got), len(want))
+			}
+			for k, v := range want {
+				if got[k] != v {
+					t.Errorf("batch %s: got %s; want %s", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestTraverser_Files_ParallelStat(t *testing.T) {
+	root := testMakeTree(t, "a/b.txt", "a/c.txt", "a/d/e.txt", "a/f/",
+		"g.txt", "h.txt", "i.txt")
+	want := testCollectFiles(t, NewTraverser(), root)
+	got := testCollectFiles(t, NewTraverser(WithWorkerNumber(3),
+		WithParallelStat(2), WithExclude("h.txt")), root)
+	var n int
+	for _, p := range want {
+		if p == "h.txt" {
+			continue
+		}
+		if n >= len(got) || got[n] != p {
+			t.Fatalf("got %q; want %q without h.txt", got, want)
+		}
+		n++
+	}
+	if n != len(got) {
+		t.Errorf("got %q; want %q without h.txt", got, want)
+	}
+}
+
+func TestTraverser_FilesWithBatch_ParallelStat(t *testing.T) {
+	fsys := testMakeWideFS(30)
+	var mu sync.Mutex
+	idxs := make(map[string]int)
+	err := NewTraverser(WithFS(fsys), WithWorkerNumber(4),
+		WithParallelStat(5)).FilesWithBatch(context.Background(),
+		func(info FileInfo, lctn *LocationBatchInfo, depth int) Action {
+			if info.Path == "wide" {
+				return ActionContinue
+			}
+			if info.Info.Size() != 0 {
+				t.Errorf("%q: size %d; want 0", info.Path, info.Info.Size())
+			}
+			var fi FileInfo
+			switch info.Cat {
+			case RegularFile:
+				fi = lctn.Batch.RegFiles[lctn.SliceIdx]
+			case Directory:
+				fi = lctn.Batch.Dirs[lctn.SliceIdx]
+			}
+			if fi.Path != info.Path {
+				t.Errorf("%q located at %q", info.Path, fi.Path)
+			}
+			mu.Lock()
+			idxs[info.Path] = lctn.SliceIdx
+			mu.Unlock()
+			return ActionContinue
+		}, "wide")
+	if err != nil {
+		t.Error(err)
+	}
+	if idxs["wide/f000.txt"] != 0 || idxs["wide/f029.txt"] != 29 {
+		t.Errorf("got %v; want files in order", idxs)
+	}
+	if len(idxs) != 32 {
+		t.Errorf("got %d files; want 32", len(idxs))
+	}
+}
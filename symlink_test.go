@@ -0,0 +1,153 @@
+//go:build unix
+
+package gotfp
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func testMakeSymlinkTree(t *testing.T) string {
+	root := testMakeTree(t, "a/b/c.txt", "d/e.txt")
+	links := [][2]string{
+		{"../..", "a/b/up"},       // Cycle: a/b/up -> root.
+		{"../a", "d/a"},           // Link to a directory.
+		{"e.txt", "d/f.txt"},      // Link to a regular file.
+		{"missing", "d/dangling"}, // Dangling link.
+	}
+	for _, link := range links {
+		err := os.Symlink(link[0], filepath.Join(root, filepath.FromSlash(link[1])))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestTraverser_Files_FollowSymlinks(t *testing.T) {
+	root := testMakeSymlinkTree(t)
+	var mu sync.Mutex
+	cats := make(map[string]FileCategory)
+	errs := make(map[string]error)
+	tr := NewTraverser(WithFollowSymlinks(true))
+	err := tr.Files(context.Background(), func(info FileInfo, depth int) Action {
+		rel, err := filepath.Rel(root, info.Path)
+		if err != nil {
+			t.Error(err)
+		}
+		rel = filepath.ToSlash(rel)
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := cats[rel]; ok {
+			t.Errorf("%q visited more than once", rel)
+		}
+		cats[rel] = info.Cat
+		errs[rel] = info.Err
+		return ActionContinue
+	}, root)
+	var te *TraversalError
+	if !errors.As(err, &te) {
+		t.Errorf("got error %v; want *TraversalError", err)
+	} else if len(te.Errs) != 2 {
+		t.Errorf("got %d errors; want 2", len(te.Errs))
+	}
+	// d/a is the same directory as a, and is descended into as well.
+	// Both a/b/up and d/a/b/up are cycles back to root.
+	want := map[string]FileCategory{
+		".":           Directory,
+		"a":           Directory,
+		"a/b":         Directory,
+		"a/b/c.txt":   RegularFile,
+		"a/b/up":      ErrorFile,
+		"d":           Directory,
+		"d/a":         Directory,
+		"d/a/b":       Directory,
+		"d/a/b/c.txt": RegularFile,
+		"d/a/b/up":    ErrorFile,
+		"d/e.txt":     RegularFile,
+		"d/f.txt":     RegularFile,
+		"d/dangling":  Symlink,
+	}
+	for k, v := range want {
+		if cats[k] != v {
+			t.Errorf("%q: got %v; want %v", k, cats[k], v)
+		}
+	}
+	if len(cats) != len(want) {
+		t.Errorf("got %v; want %v", cats, want)
+	}
+	for _, up := range []string{"a/b/up", "d/a/b/up"} {
+		var sce *SymlinkCycleError
+		if !errors.As(errs[up], &sce) {
+			t.Errorf("%q: got error %v; want *SymlinkCycleError", up, errs[up])
+		} else if sce.VisitedPath != root {
+			t.Errorf("%q: got visited path %q; want %q", up, sce.VisitedPath, root)
+		}
+	}
+}
+
+func TestTraverser_Files_FollowSymlinks_Alias(t *testing.T) {
+	root := testMakeTree(t, "x/y/z.txt", "w/v.txt")
+	// Links to directories that are not ancestors are not cycles.
+	for _, link := range [][2]string{{"../x", "w/x"}, {"x/y", "y"}} {
+		err := os.Symlink(link[0], filepath.Join(root, filepath.FromSlash(link[1])))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := testCollectFiles(t, NewTraverser(WithFollowSymlinks(true)), root)
+	want := []string{".", "w", "w/v.txt", "w/x", "w/x/y", "w/x/y/z.txt",
+		"x", "x/y", "x/y/z.txt", "y", "y/z.txt"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	// Skipping one path to a directory does not skip the others.
+	for _, skip := range []string{"x", "w/x"} {
+		var mu sync.Mutex
+		var paths []string
+		err := NewTraverser(WithFollowSymlinks(true)).Files(context.Background(),
+			func(info FileInfo, depth int) Action {
+				rel, err := filepath.Rel(root, info.Path)
+				if err != nil {
+					t.Error(err)
+				}
+				rel = filepath.ToSlash(rel)
+				mu.Lock()
+				defer mu.Unlock()
+				paths = append(paths, rel)
+				if rel == skip {
+					return ActionSkip
+				}
+				return ActionContinue
+			}, root)
+		if err != nil {
+			t.Error(err)
+		}
+		sort.Strings(paths)
+		var wantSkip []string
+		for _, p := range want {
+			if !strings.HasPrefix(p, skip+"/") {
+				wantSkip = append(wantSkip, p)
+			}
+		}
+		if strings.Join(paths, ",") != strings.Join(wantSkip, ",") {
+			t.Errorf("skip %q: got %q; want %q", skip, paths, wantSkip)
+		}
+	}
+}
+
+func TestTraverser_Files_NotFollowSymlinks(t *testing.T) {
+	root := testMakeSymlinkTree(t)
+	got := testCollectFiles(t, NewTraverser(), root)
+	want := []string{".", "a", "a/b", "a/b/c.txt", "a/b/up",
+		"d", "d/a", "d/dangling", "d/e.txt", "d/f.txt"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
@@ -1,38 +1,94 @@
 package gotfp
 
 import (
-	"errors"
-	"path/filepath"
+	"context"
+	"io/fs"
 
 	"github.com/donyori/goctpf"
 )
 
+// Deprecated: Use Traverser.Batches instead.
 func TraverseBatches(handler BatchHandler,
 	workerSettings goctpf.WorkerSettings,
 	workerErrChan chan<- error,
 	roots ...string) {
-	if handler == nil {
-		panic(errors.New("gotfp: batch handler is nil"))
-	}
-	if len(roots) == 0 {
-		// No batch to traverse. Just exit.
-		return
-	}
-	h := makeTraverseBatchesHandler(handler)
-	callDfw(h, workerSettings, workerErrChan, roots...)
+	TraverseBatchesContext(context.Background(), handler,
+		workerSettings, workerErrChan, roots...) // Ignore error.
+}
+
+// TraverseBatchesContext is like TraverseBatches, but stops scheduling new tasks
+// once ctx is done. It returns a *TraversalError if any error occurs,
+// or ctx.Err() if the traversal is stopped by ctx without other errors.
+//
+// Deprecated: Use Traverser.Batches instead.
+func TraverseBatchesContext(ctx context.Context,
+	handler BatchHandler,
+	workerSettings goctpf.WorkerSettings,
+	workerErrChan chan<- error,
+	roots ...string) error {
+	t := NewTraverser(WithWorkerSettings(workerSettings),
+		WithErrChan(workerErrChan, workerSettings.SendErrTimeout))
+	return t.Batches(ctx, handler, roots...)
+}
+
+// TraverseBatchesFS traverses roots in fsys with default settings.
+// Roots are slash-separated paths in fsys, and "." is the root of fsys.
+// It is a shortcut for NewTraverser(WithFS(fsys)).Batches(ctx, handler, roots...).
+func TraverseBatchesFS(ctx context.Context, fsys fs.FS,
+	handler BatchHandler, roots ...string) error {
+	return NewTraverser(WithFS(fsys)).Batches(ctx, handler, roots...)
 }
 
-// Ensure batchHandler != nil.
-func makeTraverseBatchesHandler(batchHandler BatchHandler) taskHandler {
+// Ensure batchHandler != nil && tr != nil.
+func makeTraverseBatchesHandler(batchHandler BatchHandler,
+	tr *tTraversal) taskHandler {
+	var deliver func(task *tTask, children []FileInfo, errBuf *[]error) (
+		newTasks []*tTask, doesExit bool)
 	h := func(task *tTask, errBuf *[]error) (newTasks []*tTask, doesExit bool) {
+		if task.Shard != nil {
+			if dir, children := tr.statShard(task); dir != nil {
+				return deliver(dir, children, errBuf)
+			}
+			return
+		}
 		path := task.FileInfo.Path
 		if task.FileInfo.Cat == 0 {
-			task.FileInfo = GetFileInfo(path)
+			tr.initRoot(task, errBuf)
 		}
-		chldn := task.FileInfo.Chldn
-		batch := Batch{Parent: task.FileInfo}
-		for i := range chldn {
-			fileInfo := GetFileInfo(filepath.Join(path, chldn[i]))
+		if task.FileInfo.Cat == Directory {
+			if !tr.descends(task) {
+				// Only roots can be here. Others are not scheduled.
+				return
+			}
+			if err := readTaskChildren(task, tr, errBuf); err != nil {
+				// Only the first chunk can fail to read.
+				task.FileInfo.Cat, task.FileInfo.Err = ErrorFile, err
+				if tr.CollectFileErrs {
+					tr.Errs.add(path, err)
+				}
+			}
+		}
+		children, shards := tr.statChildren(task)
+		if shards != nil {
+			return shards, false
+		}
+		return deliver(task, children, errBuf)
+	} // End of func h.
+	// deliver calls batchHandler with the batch of the directory of task
+	// made up of children, and returns the tasks for its sub-directories,
+	// followed by the task for the next chunk if any.
+	deliver = func(task *tTask, children []FileInfo, errBuf *[]error) (
+		newTasks []*tTask, doesExit bool) {
+		batch := Batch{
+			Parent:      task.FileInfo,
+			ChunkIdx:    task.Chunk,
+			IsLastChunk: task.DirReader == nil,
+		}
+		for _, fileInfo := range children {
+			if fileInfo.Cat != Directory &&
+				!tr.Cfg.filter.includes(task.Root, fileInfo.Path, isDir(fileInfo)) {
+				continue
+			}
 			switch fileInfo.Cat {
 			case ErrorFile:
 				batch.Errs = append(batch.Errs, fileInfo)
@@ -51,7 +107,13 @@ func makeTraverseBatchesHandler(batchHandler BatchHandler) taskHandler {
 		}
 		// Copy batch.Dirs. See https://github.com/go101/go101/wiki for details.
 		dirs := append(batch.Dirs[:0:0], batch.Dirs...)
-		action, skipDirs := batchHandler(batch, task.Depth)
+		// Directories at the maximum depth or on other file systems
+		// are not read, so not scheduled.
+		isDescended := tr.reads(task.Depth + 1)
+		action, skipDirs := ActionContinue, map[string]bool(nil)
+		if task.Depth >= tr.Cfg.minDepth {
+			action, skipDirs = batchHandler(batch, task.Depth)
+		}
 		switch action {
 		case ActionContinue:
 			// Do nothing here.
@@ -59,6 +121,11 @@ func makeTraverseBatchesHandler(batchHandler BatchHandler) taskHandler {
 		case ActionExit:
 			return nil, true
 		case ActionSkip:
+			// Skipping only applies to the sub-directories in this batch.
+			// The rest chunks of the directory are still read.
+			if next := nextChunkTask(task); next != nil {
+				newTasks = append(newTasks, next)
+			}
 			if len(skipDirs) == 0 {
 				// Skip all sub-directories.
 				return
@@ -73,10 +140,16 @@ func makeTraverseBatchesHandler(batchHandler BatchHandler) taskHandler {
 				}
 				j++
 			}
-			if j > 0 {
-				newTasks = make([]*tTask, 0, j)
+			if j > 0 && isDescended {
 				for k := 0; k < j; k++ {
-					newTasks = append(newTasks, &tTask{FileInfo: dirs[k]})
+					if dirs[k].MountPoint {
+						continue
+					}
+					newTasks = append(newTasks, &tTask{
+						FileInfo: dirs[k],
+						Root:     task.Root,
+						Ignore:   task.Ignore,
+					})
 				}
 			}
 			if i == j {
@@ -86,13 +159,23 @@ func makeTraverseBatchesHandler(batchHandler BatchHandler) taskHandler {
 		default:
 			*errBuf = append(*errBuf, NewUnknownActionError(action))
 		}
-		if len(dirs) > 0 {
-			newTasks = make([]*tTask, 0, len(dirs))
+		if len(dirs) > 0 && isDescended {
+			newTasks = make([]*tTask, 0, len(dirs)+1)
 			for i := range dirs {
-				newTasks = append(newTasks, &tTask{FileInfo: dirs[i]})
+				if dirs[i].MountPoint {
+					continue
+				}
+				newTasks = append(newTasks, &tTask{
+					FileInfo: dirs[i],
+					Root:     task.Root,
+					Ignore:   task.Ignore,
+				})
 			}
 		}
+		if next := nextChunkTask(task); next != nil {
+			newTasks = append(newTasks, next)
+		}
 		return
-	} // End of func h.
+	} // End of func deliver.
 	return h
 }
@@ -1,66 +1,119 @@
 package gotfp
 
 import (
-	"errors"
-	"path/filepath"
+	"context"
+	"io/fs"
 	"sort"
 
 	"github.com/donyori/goctpf"
 )
 
+// Deprecated: Use Traverser.Files instead.
 func TraverseFiles(handler FileHandler,
 	workerSettings goctpf.WorkerSettings,
 	workerErrChan chan<- error,
 	roots ...string) {
-	if handler == nil {
-		panic(errors.New("gotfp: file handler is nil"))
-	}
-	if len(roots) == 0 {
-		// No file to traverse. Just exit.
-		return
-	}
-	h := makeTraverseFilesHandler(handler)
-	callDfw(h, workerSettings, workerErrChan, roots...)
+	TraverseFilesContext(context.Background(), handler,
+		workerSettings, workerErrChan, roots...) // Ignore error.
 }
 
-// Ensure fileHandler != nil.
-func makeTraverseFilesHandler(fileHandler FileHandler) taskHandler {
-	h := func(task *tTask, errBuf *[]error) (newTasks []*tTask, doesExit bool) {
-		path := task.FileInfo.Path
-		if task.FileInfo.Cat == 0 {
-			task.FileInfo = GetFileInfo(path)
+// TraverseFilesContext is like TraverseFiles, but stops scheduling new tasks
+// once ctx is done. It returns a *TraversalError if any error occurs,
+// or ctx.Err() if the traversal is stopped by ctx without other errors.
+//
+// Deprecated: Use Traverser.Files instead.
+func TraverseFilesContext(ctx context.Context,
+	handler FileHandler,
+	workerSettings goctpf.WorkerSettings,
+	workerErrChan chan<- error,
+	roots ...string) error {
+	t := NewTraverser(WithWorkerSettings(workerSettings),
+		WithErrChan(workerErrChan, workerSettings.SendErrTimeout))
+	return t.Files(ctx, handler, roots...)
+}
+
+// TraverseFilesFS traverses roots in fsys with default settings.
+// Roots are slash-separated paths in fsys, and "." is the root of fsys.
+// It is a shortcut for NewTraverser(WithFS(fsys)).Files(ctx, handler, roots...).
+func TraverseFilesFS(ctx context.Context, fsys fs.FS,
+	handler FileHandler, roots ...string) error {
+	return NewTraverser(WithFS(fsys)).Files(ctx, handler, roots...)
+}
+
+// Ensure fileHandler != nil && tr != nil.
+func makeTraverseFilesHandler(fileHandler FileHandler,
+	tr *tTraversal) taskHandler {
+	// makeChildTasks returns the tasks for children of the directory of task,
+	// followed by the task for the next chunk if any.
+	makeChildTasks := func(task *tTask, children []FileInfo) []*tTask {
+		newTasks := make([]*tTask, 0, len(children)+1)
+		for i := range children {
+			newTasks = append(newTasks, &tTask{
+				FileInfo: children[i],
+				Root:     task.Root,
+				Ignore:   task.Ignore,
+			})
+		}
+		switch tr.Cfg.sortOrder {
+		case SortByCategory:
+			sort.Slice(newTasks, func(i, j int) bool {
+				t1 := newTasks[i]
+				t2 := newTasks[j]
+				if t1.FileInfo.Cat == t2.FileInfo.Cat {
+					return t1.FileInfo.Path < t2.FileInfo.Path
+				}
+				return t1.FileInfo.Cat < t2.FileInfo.Cat
+			})
+		case SortByName:
+			// Children are already sorted by name.
 		}
-		// Copy task.FileInfo.Chldn. See https://github.com/go101/go101/wiki for details.
-		chldn := append(task.FileInfo.Chldn[:0:0], task.FileInfo.Chldn...)
-		action := fileHandler(task.FileInfo, task.Depth)
-		switch action {
-		case ActionContinue:
-			// Do nothing here.
-		case ActionExit:
-			return nil, true
-		case ActionSkip:
+		if next := nextChunkTask(task); next != nil {
+			newTasks = append(newTasks, next)
+		}
+		return newTasks
+	}
+	h := func(task *tTask, errBuf *[]error) (newTasks []*tTask, doesExit bool) {
+		if task.Shard != nil {
+			if dir, children := tr.statShard(task); dir != nil {
+				newTasks = makeChildTasks(dir, children)
+			}
 			return
-		default:
-			*errBuf = append(*errBuf, NewUnknownActionError(action))
 		}
-		if len(chldn) == 0 {
+		// The handler has been called for the directory
+		// if the task is for a chunk other than the first.
+		if task.Chunk == 0 {
+			if task.FileInfo.Cat == 0 {
+				tr.initRoot(task, errBuf)
+			}
+			action := ActionContinue
+			if tr.handles(task, isDir(task.FileInfo)) {
+				action = fileHandler(task.FileInfo, task.Depth)
+			}
+			switch action {
+			case ActionContinue:
+				// Do nothing here.
+			case ActionExit:
+				return nil, true
+			case ActionSkip:
+				return
+			default:
+				*errBuf = append(*errBuf, NewUnknownActionError(action))
+			}
+			// Read the children only after the handler decides to continue,
+			// so skipped directories are never read.
+			if !tr.descends(task) {
+				return
+			}
+		}
+		if err := readTaskChildren(task, tr, errBuf); err != nil {
+			*errBuf = append(*errBuf, err)
 			return
 		}
-		newTasks = make([]*tTask, 0, len(chldn))
-		for i := range chldn {
-			newTasks = append(newTasks, &tTask{
-				FileInfo: GetFileInfo(filepath.Join(path, chldn[i])),
-			})
+		children, shards := tr.statChildren(task)
+		if shards != nil {
+			return shards, false
 		}
-		sort.Slice(newTasks, func(i, j int) bool {
-			t1 := newTasks[i]
-			t2 := newTasks[j]
-			if t1.FileInfo.Cat == t2.FileInfo.Cat {
-				return t1.FileInfo.Path < t2.FileInfo.Path
-			}
-			return t1.FileInfo.Cat < t2.FileInfo.Cat
-		})
-		return
+		return makeChildTasks(task, children), false
 	} // End of func h.
 	return h
 }
@@ -1,103 +1,162 @@
 package gotfp
 
 import (
-	"errors"
-	"path/filepath"
+	"context"
+	"io/fs"
 
 	"github.com/donyori/goctpf"
 )
 
+// Deprecated: Use Traverser.FilesWithBatch instead.
 func TraverseFilesWithBatch(handler FileWithBatchHandler,
 	workerSettings goctpf.WorkerSettings,
 	workerErrChan chan<- error,
 	roots ...string) {
-	if handler == nil {
-		panic(errors.New("gotfp: file handler is nil"))
-	}
-	if len(roots) == 0 {
-		// No file to traverse. Just exit.
-		return
-	}
-	h := makeTraverseFilesWithBatchHandler(handler)
-	callDfw(h, workerSettings, workerErrChan, roots...)
+	TraverseFilesWithBatchContext(context.Background(), handler,
+		workerSettings, workerErrChan, roots...) // Ignore error.
 }
 
-// Ensure fileWithBatchHandler != nil.
+// TraverseFilesWithBatchContext is like TraverseFilesWithBatch, but stops scheduling new tasks
+// once ctx is done. It returns a *TraversalError if any error occurs,
+// or ctx.Err() if the traversal is stopped by ctx without other errors.
+//
+// Deprecated: Use Traverser.FilesWithBatch instead.
+func TraverseFilesWithBatchContext(ctx context.Context,
+	handler FileWithBatchHandler,
+	workerSettings goctpf.WorkerSettings,
+	workerErrChan chan<- error,
+	roots ...string) error {
+	t := NewTraverser(WithWorkerSettings(workerSettings),
+		WithErrChan(workerErrChan, workerSettings.SendErrTimeout))
+	return t.FilesWithBatch(ctx, handler, roots...)
+}
+
+// TraverseFilesWithBatchFS traverses roots in fsys with default settings.
+// Roots are slash-separated paths in fsys, and "." is the root of fsys.
+// It is a shortcut for NewTraverser(WithFS(fsys)).FilesWithBatch(ctx, handler, roots...).
+func TraverseFilesWithBatchFS(ctx context.Context, fsys fs.FS,
+	handler FileWithBatchHandler, roots ...string) error {
+	return NewTraverser(WithFS(fsys)).FilesWithBatch(ctx, handler, roots...)
+}
+
+// Ensure fileWithBatchHandler != nil && tr != nil.
 func makeTraverseFilesWithBatchHandler(
-	fileWithBatchHandler FileWithBatchHandler) taskHandler {
+	fileWithBatchHandler FileWithBatchHandler, tr *tTraversal) taskHandler {
+	var deliver func(task *tTask, children []FileInfo, errBuf *[]error) []*tTask
 	h := func(task *tTask, errBuf *[]error) (newTasks []*tTask, doesExit bool) {
-		path := task.FileInfo.Path
-		if task.FileInfo.Cat == 0 {
-			task.FileInfo = GetFileInfo(path)
+		if task.Shard != nil {
+			if dir, children := tr.statShard(task); dir != nil {
+				newTasks = deliver(dir, children, errBuf)
+			}
+			return
 		}
-		// Copy task.FileInfo.Chldn. See https://github.com/go101/go101/wiki for details.
-		chldn := append(task.FileInfo.Chldn[:0:0], task.FileInfo.Chldn...)
-		var lctn *LocationBatchInfo
-		if task.ExInfo != nil {
-			lctn = task.ExInfo.(*LocationBatchInfo)
-		} else if path != "" {
-			parent := filepath.Dir(path)
-			if parent != path { // path is not a root file path.
-				batch := &Batch{Parent: GetFileInfo(parent)}
-				lctn = &LocationBatchInfo{Batch: batch}
-				if len(batch.Parent.Chldn) > 0 {
-					pathBase := filepath.Base(path)
-					for _, name := range batch.Parent.Chldn {
-						var fileInfo FileInfo
-						if pathBase != name {
-							fileInfo = GetFileInfo(filepath.Join(parent, name))
-						} else {
-							fileInfo = task.FileInfo
+		path := task.FileInfo.Path
+		// The handler has been called for the directory
+		// if the task is for a chunk other than the first.
+		if task.Chunk == 0 {
+			if task.FileInfo.Cat == 0 {
+				tr.initRoot(task, errBuf)
+			}
+			var lctn *LocationBatchInfo
+			if task.ExInfo != nil {
+				lctn = task.ExInfo.(*LocationBatchInfo)
+			} else if path != "" {
+				parent := tr.FS.Dir(path)
+				if parent != path { // path is not a root file path.
+					parentInfo, _ := getFileInfo(parent, nil, tr, nil, false)
+					if parentInfo.Cat == Directory {
+						if err := readChildren(&parentInfo, tr); err != nil {
+							parentInfo.Cat, parentInfo.Err = ErrorFile, err
+						}
+					}
+					batch := &Batch{Parent: parentInfo, IsLastChunk: true}
+					lctn = &LocationBatchInfo{Batch: batch}
+					if len(batch.Parent.entries) > 0 {
+						pathBase := tr.FS.Base(path)
+						for _, entry := range batch.Parent.entries {
+							var fileInfo FileInfo
+							if pathBase != entry.Name() {
+								fileInfo, _ = getFileInfo(tr.FS.Join(parent,
+									entry.Name()), entry, tr, nil, false)
+							} else {
+								fileInfo = task.FileInfo
+								switch fileInfo.Cat {
+								case ErrorFile:
+									lctn.SliceIdx = len(batch.Errs)
+								case RegularFile:
+									lctn.SliceIdx = len(batch.RegFiles)
+								case OtherFile:
+									lctn.SliceIdx = len(batch.Others)
+								case Symlink:
+									lctn.SliceIdx = len(batch.Symlinks)
+								case Directory:
+									lctn.SliceIdx = len(batch.Dirs)
+								}
+								// UnknownFileCategoryError will be reported in the following step.
+							}
 							switch fileInfo.Cat {
 							case ErrorFile:
-								lctn.SliceIdx = len(batch.Errs)
+								batch.Errs = append(batch.Errs, fileInfo)
 							case RegularFile:
-								lctn.SliceIdx = len(batch.RegFiles)
+								batch.RegFiles = append(batch.RegFiles, fileInfo)
 							case OtherFile:
-								lctn.SliceIdx = len(batch.Others)
+								batch.Others = append(batch.Others, fileInfo)
 							case Symlink:
-								lctn.SliceIdx = len(batch.Symlinks)
+								batch.Symlinks = append(batch.Symlinks, fileInfo)
 							case Directory:
-								lctn.SliceIdx = len(batch.Dirs)
+								batch.Dirs = append(batch.Dirs, fileInfo)
+							default:
+								*errBuf = append(*errBuf,
+									NewUnknownFileCategoryError(fileInfo.Cat))
 							}
-							// UnknownFileCategoryError will be reported in the following step.
-						}
-						switch fileInfo.Cat {
-						case ErrorFile:
-							batch.Errs = append(batch.Errs, fileInfo)
-						case RegularFile:
-							batch.RegFiles = append(batch.RegFiles, fileInfo)
-						case OtherFile:
-							batch.Others = append(batch.Others, fileInfo)
-						case Symlink:
-							batch.Symlinks = append(batch.Symlinks, fileInfo)
-						case Directory:
-							batch.Dirs = append(batch.Dirs, fileInfo)
-						default:
-							*errBuf = append(*errBuf,
-								NewUnknownFileCategoryError(fileInfo.Cat))
 						}
 					}
 				}
 			}
+			action := ActionContinue
+			if tr.handles(task, isDir(task.FileInfo)) {
+				action = fileWithBatchHandler(task.FileInfo, lctn, task.Depth)
+			}
+			switch action {
+			case ActionContinue:
+				// Do nothing here.
+			case ActionExit:
+				return nil, true
+			case ActionSkip:
+				return
+			default:
+				*errBuf = append(*errBuf, NewUnknownActionError(action))
+			}
+			// Read the children only after the handler decides to continue,
+			// so skipped directories are never read.
+			if !tr.descends(task) {
+				return
+			}
 		}
-		action := fileWithBatchHandler(task.FileInfo, lctn, task.Depth)
-		switch action {
-		case ActionContinue:
-			// Do nothing here.
-		case ActionExit:
-			return nil, true
-		case ActionSkip:
+		if err := readTaskChildren(task, tr, errBuf); err != nil {
+			*errBuf = append(*errBuf, err)
 			return
-		default:
-			*errBuf = append(*errBuf, NewUnknownActionError(action))
 		}
-		if len(chldn) == 0 {
-			return
+		children, shards := tr.statChildren(task)
+		if shards != nil {
+			return shards, false
+		}
+		return deliver(task, children, errBuf), false
+	} // End of func h.
+	// deliver returns the tasks for children of the directory of task,
+	// located in the batch made up of them, followed by the task for
+	// the next chunk if any.
+	deliver = func(task *tTask, children []FileInfo, errBuf *[]error) []*tTask {
+		batch := &Batch{
+			Parent:      task.FileInfo,
+			ChunkIdx:    task.Chunk,
+			IsLastChunk: task.DirReader == nil,
 		}
-		batch := &Batch{Parent: task.FileInfo}
-		for i := range chldn {
-			fileInfo := GetFileInfo(filepath.Join(path, chldn[i]))
+		for _, fileInfo := range children {
+			if fileInfo.Cat != Directory &&
+				!tr.Cfg.filter.includes(task.Root, fileInfo.Path, isDir(fileInfo)) {
+				continue
+			}
 			switch fileInfo.Cat {
 			case ErrorFile:
 				batch.Errs = append(batch.Errs, fileInfo)
@@ -114,22 +173,27 @@ func makeTraverseFilesWithBatchHandler(
 					NewUnknownFileCategoryError(fileInfo.Cat))
 			}
 		}
-		newTasks = make([]*tTask, 0, len(chldn))
+		newTasks := make([]*tTask, 0, len(children)+1)
 		slices := [...][]FileInfo{batch.Errs, batch.RegFiles,
 			batch.Others, batch.Symlinks, batch.Dirs}
 		for _, slice := range slices {
 			for i := range slice {
-				lctn = &LocationBatchInfo{
+				lctn := &LocationBatchInfo{
 					Batch:    batch,
 					SliceIdx: i,
 				}
 				newTasks = append(newTasks, &tTask{
 					FileInfo: slice[i],
+					Root:     task.Root,
+					Ignore:   task.Ignore,
 					ExInfo:   lctn,
 				})
 			}
 		}
-		return
-	} // End of func h.
+		if next := nextChunkTask(task); next != nil {
+			newTasks = append(newTasks, next)
+		}
+		return newTasks
+	} // End of func deliver.
 	return h
 }
@@ -0,0 +1,109 @@
+package gotfp
+
+import "sort"
+
+// Ensure fileValueHandler != nil && leaveHandler != nil && tr != nil.
+func makeTraverseFilesWithLeaveHandler(fileValueHandler FileValueHandler,
+	leaveHandler LeaveHandler, tr *tTraversal) taskHandler {
+	if minDepth := tr.Cfg.minDepth; minDepth > 0 {
+		// Directories above minDepth are still left, without callback.
+		leave := leaveHandler
+		leaveHandler = func(dir FileInfo, depth int,
+			childValues []interface{}) interface{} {
+			if depth < minDepth {
+				return nil
+			}
+			return leave(dir, depth, childValues)
+		}
+	}
+	// makeChildTasks returns the tasks for children of the directory of task,
+	// followed by the task for the next chunk if any.
+	makeChildTasks := func(task *tTask, children []FileInfo) []*tTask {
+		node := task.ExInfo.(*tLeaveLink).Node
+		base := node.grow(len(children))
+		newTasks := make([]*tTask, 0, len(children)+1)
+		for i := range children {
+			newTasks = append(newTasks, &tTask{
+				FileInfo: children[i],
+				Root:     task.Root,
+				Ignore:   task.Ignore,
+				ExInfo:   &tLeaveLink{Parent: node, Idx: base + i},
+			})
+		}
+		switch tr.Cfg.sortOrder {
+		case SortByCategory:
+			sort.Slice(newTasks, func(i, j int) bool {
+				t1 := newTasks[i]
+				t2 := newTasks[j]
+				if t1.FileInfo.Cat == t2.FileInfo.Cat {
+					return t1.FileInfo.Path < t2.FileInfo.Path
+				}
+				return t1.FileInfo.Cat < t2.FileInfo.Cat
+			})
+		case SortByName:
+			// Children are already sorted by name.
+		}
+		if next := nextChunkTask(task); next != nil {
+			newTasks = append(newTasks, next)
+		} else {
+			// All children are known. Leave the directory after they are done.
+			node.done(-1, nil, false, leaveHandler)
+		}
+		return newTasks
+	}
+	h := func(task *tTask, errBuf *[]error) (newTasks []*tTask, doesExit bool) {
+		if task.Shard != nil {
+			if dir, children := tr.statShard(task); dir != nil {
+				newTasks = makeChildTasks(dir, children)
+			}
+			return
+		}
+		if task.ExInfo == nil {
+			// Roots have no parent.
+			task.ExInfo = new(tLeaveLink)
+		}
+		link := task.ExInfo.(*tLeaveLink)
+		// The handler has been called for the directory
+		// if the task is for a chunk other than the first.
+		if task.Chunk == 0 {
+			if task.FileInfo.Cat == 0 {
+				tr.initRoot(task, errBuf)
+			}
+			action := ActionContinue
+			var value interface{}
+			isHandled := tr.handles(task, isDir(task.FileInfo))
+			if isHandled {
+				action, value = fileValueHandler(task.FileInfo, task.Depth)
+			}
+			switch action {
+			case ActionContinue:
+				// Do nothing here.
+			case ActionExit:
+				return nil, true
+			case ActionSkip:
+				link.Parent.done(link.Idx, value, isHandled, leaveHandler)
+				return
+			default:
+				*errBuf = append(*errBuf, NewUnknownActionError(action))
+			}
+			if !tr.descends(task) {
+				link.Parent.done(link.Idx, value, isHandled, leaveHandler)
+				return
+			}
+			// The value of the directory is given by leaveHandler.
+			link.Node = newLeaveNode(task, link)
+		}
+		if err := readTaskChildren(task, tr, errBuf); err != nil {
+			*errBuf = append(*errBuf, err)
+			// Leave the directory without children.
+			link.Node.done(-1, nil, false, leaveHandler)
+			return
+		}
+		children, shards := tr.statChildren(task)
+		if shards != nil {
+			return shards, false
+		}
+		return makeChildTasks(task, children), false
+	} // End of func h.
+	return h
+}
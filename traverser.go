@@ -0,0 +1,547 @@
+package gotfp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"runtime"
+	"time"
+
+	"github.com/donyori/goctpf"
+)
+
+// Traverser traverses files in parallel.
+// It is configured by options when created and can be reused,
+// also concurrently.
+type Traverser struct {
+	cfg config
+}
+
+// Option configures a Traverser.
+type Option func(cfg *config)
+
+type config struct {
+	workerSettings goctpf.WorkerSettings
+	workerErrChan  chan<- error
+	sortOrder      SortOrder
+	order          TraversalOrder
+	followSymlinks bool
+	filter         tFilter
+
+	gitignore            bool
+	gitExcludesFile      string
+	isGitExcludesFileSet bool
+
+	fsys fs.FS
+
+	maxErrors int
+
+	chunkSize int
+
+	parallelStatThreshold int
+
+	maxBuffered int // Positive for ordered delivery.
+
+	minDepth int
+	maxDepth int // Negative for no limit.
+
+	sameFileSystem bool
+
+	mountInfoPath  string
+	isMountInfoSet bool
+	fsTypeExcludes []string
+
+	hardLinks HardLinkPolicy
+}
+
+// NewTraverser creates a Traverser with given options.
+//
+// By default, it uses GOMAXPROCS workers, discards worker errors,
+// sorts children by category and then path (SortByCategory),
+// and visits files depth-first (DepthFirst).
+func NewTraverser(opts ...Option) *Traverser {
+	t := &Traverser{cfg: config{
+		workerSettings: goctpf.WorkerSettings{
+			Number: uint32(runtime.GOMAXPROCS(0)),
+		},
+		sortOrder: SortByCategory,
+		order:     DepthFirst,
+		maxDepth:  -1,
+		hardLinks: KeepHardLinks,
+	}}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&t.cfg)
+		}
+	}
+	return t
+}
+
+// WithWorkerNumber sets the number of workers.
+// Non-positive n stands for GOMAXPROCS.
+func WithWorkerNumber(n int) Option {
+	return func(cfg *config) {
+		if n <= 0 {
+			n = runtime.GOMAXPROCS(0)
+		}
+		cfg.workerSettings.Number = uint32(n)
+	}
+}
+
+// WithWorkerSettings sets the worker settings directly.
+func WithWorkerSettings(workerSettings goctpf.WorkerSettings) Option {
+	return func(cfg *config) {
+		cfg.workerSettings = workerSettings
+	}
+}
+
+// WithErrChan sets a channel to receive errors reported by workers
+// (e.g. UnknownActionError, ErrNoDirToSkip) during the traversal.
+// Errors are dropped if they cannot be sent within sendTimeout.
+// They are also returned in the *TraversalError regardless of errChan.
+func WithErrChan(errChan chan<- error, sendTimeout time.Duration) Option {
+	return func(cfg *config) {
+		cfg.workerErrChan = errChan
+		cfg.workerSettings.SendErrTimeout = sendTimeout
+	}
+}
+
+// WithMaxErrors sets the maximum number of errors kept in
+// the *TraversalError returned by the methods of Traverser.
+// Errors exceeding the limit are only counted in TraversalError.NumDropped.
+// Non-positive n stands for no limit, which is the default.
+func WithMaxErrors(n int) Option {
+	return func(cfg *config) {
+		cfg.maxErrors = n
+	}
+}
+
+// WithSortOrder sets the order of children of a directory.
+func WithSortOrder(order SortOrder) Option {
+	return func(cfg *config) {
+		if order < SortByCategory || order > SortNone {
+			panic(NewUnknownSortOrderError(order))
+		}
+		cfg.sortOrder = order
+	}
+}
+
+// WithTraversalOrder sets the order in which files are visited.
+//
+// In all orders, a directory is handled before its children
+// (in Batches, the batch of a directory before those of its sub-directories).
+// Other guarantees depend on order:
+//
+//   - DepthFirst (default): files are visited in a load-balanced depth-first
+//     order. Each worker goes deep into a directory, and idle workers take
+//     tasks from the others. Nothing else about the order is guaranteed.
+//   - BreadthFirst: files are started in the order they are found.
+//     With a single worker, all files at a depth are visited before
+//     any file deeper. With more workers, shallower files tend to be
+//     visited first, but a file may be started before a shallower one
+//     found later by a slower worker. When reading directories chunk by
+//     chunk (see WithChunkSize), the next chunk of a directory is queued
+//     after the files found in the current chunk, so this only holds
+//     within a chunk.
+//   - LevelSync: files are visited level by level, with a barrier between
+//     depths: all handlers for files at depth d return before any handler
+//     for a file at depth d+1 is called. The order within a level is not
+//     guaranteed.
+func WithTraversalOrder(order TraversalOrder) Option {
+	return func(cfg *config) {
+		if order < DepthFirst || order > LevelSync {
+			panic(NewUnknownTraversalOrderError(order))
+		}
+		cfg.order = order
+	}
+}
+
+// WithFollowSymlinks sets whether to follow symbolic links.
+//
+// If follow is true, a symbolic link is reported as its target
+// (keeping its own path), and a link to a directory is descended into.
+// Dangling links are still reported as Symlink.
+// A directory that is the same as one of its ancestors is not descended
+// into, and is reported as an ErrorFile with a *SymlinkCycleError,
+// so that link cycles cannot hang the traversal. Any other directory
+// reachable through several paths (e.g., a link to a sibling) is reported
+// and descended into through each of them, as find -L does.
+// Directories are identified by their device and inode numbers,
+// or, where these are unavailable (e.g., on an fs.FS set by WithFS),
+// by their paths with all symbolic links resolved in the file system
+// traversed.
+func WithFollowSymlinks(follow bool) Option {
+	return func(cfg *config) {
+		cfg.followSymlinks = follow
+	}
+}
+
+// WithInclude adds include patterns.
+//
+// Patterns are matched by MatchGlob against the slash-separated path
+// relative to the root, and a pattern with a trailing slash
+// only matches directories.
+// If there is any include pattern, only files matching at least one of them
+// are reported. Directories are still descended into even if they are not
+// reported, and they are always kept in Batch.Dirs.
+// Roots are always reported.
+//
+// It panics if any pattern is malformed.
+func WithInclude(patterns ...string) Option {
+	globs := make([]tGlob, len(patterns))
+	for i := range patterns {
+		globs[i] = newGlob(patterns[i])
+	}
+	return func(cfg *config) {
+		cfg.filter.Includes = append(cfg.filter.Includes, globs...)
+	}
+}
+
+// WithExclude adds exclude patterns, with the same syntax as WithInclude.
+//
+// Files matching any exclude pattern are neither reported nor read.
+// In particular, excluded directories are pruned, so their children are
+// never listed. Roots are never excluded.
+//
+// It panics if any pattern is malformed.
+func WithExclude(patterns ...string) Option {
+	globs := make([]tGlob, len(patterns))
+	for i := range patterns {
+		globs[i] = newGlob(patterns[i])
+	}
+	return func(cfg *config) {
+		cfg.filter.Excludes = append(cfg.filter.Excludes, globs...)
+	}
+}
+
+// WithGitignore sets whether to skip files ignored by git.
+//
+// If enable is true, rules are loaded from .gitignore files as the
+// traversal descends, as well as from .git/info/exclude of each repository
+// and the global excludes file (see WithGitExcludesFile).
+// If a root is inside a repository, the .gitignore files from the top of
+// the repository down to the root also apply.
+// Ignored files are neither reported nor read, like those excluded by
+// WithExclude. The .git directories are always skipped.
+// Roots are never ignored.
+func WithGitignore(enable bool) Option {
+	return func(cfg *config) {
+		cfg.gitignore = enable
+	}
+}
+
+// WithGitExcludesFile sets the global excludes file used with WithGitignore.
+// An empty filename disables the global excludes file.
+//
+// By default, it is core.excludesFile in the global git configuration,
+// or $XDG_CONFIG_HOME/git/ignore if not set.
+func WithGitExcludesFile(filename string) Option {
+	return func(cfg *config) {
+		cfg.gitExcludesFile = filename
+		cfg.isGitExcludesFileSet = true
+	}
+}
+
+// WithFS sets the file system to traverse.
+//
+// Roots and paths in FileInfo are then slash-separated paths in fsys
+// (see io/fs.ValidPath), and "." is the root of fsys.
+// fs.ReadDirFS and fs.StatFS are used if fsys implements them,
+// and symbolic links are recognized only if fsys implements fs.ReadLinkFS.
+// A nil fsys stands for the file system of the operating system,
+// which is the default.
+func WithFS(fsys fs.FS) Option {
+	return func(cfg *config) {
+		cfg.fsys = fsys
+	}
+}
+
+// WithChunkSize sets the number of entries read from a directory at a time.
+//
+// If n is positive, a directory is read n entries at a time, and each
+// chunk of entries is handed to the workers as soon as it is read,
+// so a huge directory neither needs to be held in memory nor stalls
+// a single worker. Entries are then sorted within each chunk only,
+// and not sorted at all with SortNone.
+// Batches are delivered per chunk: see Batch.ChunkIdx and
+// Batch.IsLastChunk. Batch.Parent.Chldn only holds the names in the chunk.
+//
+// Non-positive n stands for reading all entries at once, which is
+// the default.
+func WithChunkSize(n int) Option {
+	return func(cfg *config) {
+		if n < 0 {
+			n = 0
+		}
+		cfg.chunkSize = n
+	}
+}
+
+// WithParallelStat makes the children of a directory categorized and stat
+// in parallel by all workers if there are at least threshold of them
+// (in a chunk, see WithChunkSize). The results are merged back in order,
+// so the batches and the order of the files stay the same.
+// Their FileInfo.Info is then loaded in advance.
+//
+// Directories with fewer children are handled by a single worker,
+// and their FileInfo.Info is loaded lazily.
+// Non-positive threshold stands for never doing so, which is the default.
+func WithParallelStat(threshold int) Option {
+	return func(cfg *config) {
+		if threshold < 0 {
+			threshold = 0
+		}
+		cfg.parallelStatThreshold = threshold
+	}
+}
+
+// WithOrderedDelivery makes the handlers called one at a time,
+// in the goroutine calling the traversal, in lexical pre-order as
+// filepath.WalkDir does: a file, and then, if it is a directory,
+// each of its children in the order of name, followed by its subtree.
+// In Batches, the batches of directories are delivered in the same order.
+// Roots are delivered in the order given.
+// ActionSkip and ActionExit keep their meanings.
+//
+// Directories are still read, and their entries stat, by the workers
+// in parallel, ahead of the delivery. The entries read ahead are kept
+// until the subtree of their directory is delivered.
+// maxBuffered limits the number of such entries, and thus the memory
+// used. It is a soft limit: the directory that the delivery waits for
+// is always read, so the traversal never stalls.
+//
+// Non-positive maxBuffered stands for parallel delivery, which is the default.
+// In ordered delivery, directories are always read as a whole
+// (WithChunkSize and WithParallelStat have no effect), and
+// WithTraversalOrder has no effect.
+func WithOrderedDelivery(maxBuffered int) Option {
+	return func(cfg *config) {
+		if maxBuffered < 0 {
+			maxBuffered = 0
+		}
+		cfg.maxBuffered = maxBuffered
+	}
+}
+
+// WithMinDepth makes the handlers not called for files at a depth
+// less than n. Roots are at depth 0.
+// The files are still traversed, and their directories read.
+// In Batches, the depth of a batch is that of its parent.
+//
+// Non-positive n stands for no limit, which is the default.
+func WithMinDepth(n int) Option {
+	return func(cfg *config) {
+		if n < 0 {
+			n = 0
+		}
+		cfg.minDepth = n
+	}
+}
+
+// WithMaxDepth makes files at a depth greater than n not traversed.
+// Roots are at depth 0.
+// Directories at depth n are reported but never read, so no readdir is
+// issued for them. In Batches, they only appear in the batches of their
+// parents, and have no batches of their own.
+//
+// Negative n stands for no limit, which is the default.
+func WithMaxDepth(n int) Option {
+	return func(cfg *config) {
+		if n < 0 {
+			n = -1
+		}
+		cfg.maxDepth = n
+	}
+}
+
+// WithSameFileSystem sets whether to stay on the file systems of roots,
+// like the -xdev option of find.
+//
+// If same is true, the device number of each root is recorded,
+// and a directory on a different device from its root is reported
+// with FileInfo.MountPoint set to true, but never descended into.
+// Device numbers are only available on Unix. Elsewhere, it has no effect.
+func WithSameFileSystem(same bool) Option {
+	return func(cfg *config) {
+		cfg.sameFileSystem = same
+	}
+}
+
+// WithMountInfo loads the mount table from the file name, in the format
+// of /proc/self/mountinfo, when the traversal starts, and sets
+// FileInfo.Mount of directories that are mount points.
+// An empty name stands for mountinfo.DefaultPath.
+//
+// Directories are looked up by their paths, so a mount point reached
+// through a symbolic link is not recognized.
+// The mount table is not used when traversing an fs.FS (see WithFS).
+// If the mount table cannot be read, the error is reported
+// in the *TraversalError, and the traversal goes on without it.
+func WithMountInfo(name string) Option {
+	return func(cfg *config) {
+		cfg.mountInfoPath, cfg.isMountInfoSet = name, true
+	}
+}
+
+// WithExcludeFSTypes adds patterns of file system types to exclude,
+// e.g., "proc", "sysfs", "cgroup2" and "fuse.*".
+// Patterns are matched by path.Match.
+//
+// Mount points of the file systems of such types are neither reported
+// nor read, like those excluded by WithExclude. Roots are never excluded.
+// It loads the mount table as WithMountInfo does, from
+// mountinfo.DefaultPath unless WithMountInfo sets another one.
+//
+// It panics if any pattern is malformed.
+func WithExcludeFSTypes(patterns ...string) Option {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			panic(err)
+		}
+	}
+	patterns = append(patterns[:0:0], patterns...)
+	return func(cfg *config) {
+		cfg.fsTypeExcludes = append(cfg.fsTypeExcludes, patterns...)
+	}
+}
+
+// WithHardLinks sets how to handle files (other than directories)
+// with several hard links, each seen through several paths:
+//
+//   - KeepHardLinks (default): report the file for every path.
+//   - SkipHardLinks: report the file only for the first path seen,
+//     as if the others were excluded. Roots are always reported.
+//   - MarkHardLinks: report the file for every path, with FileInfo.HardLink
+//     set, so that the repeats can be told, e.g., to count the disk usage
+//     of the file once.
+//
+// Files are identified by their device and inode numbers, which are only
+// available on Unix. Elsewhere, it has no effect.
+// The path seen first is the first one through which the workers
+// get the file information, which is not necessarily the first one
+// passed to the handler, even with WithOrderedDelivery.
+func WithHardLinks(policy HardLinkPolicy) Option {
+	return func(cfg *config) {
+		if policy < KeepHardLinks || policy > MarkHardLinks {
+			panic(NewUnknownHardLinkPolicyError(policy))
+		}
+		cfg.hardLinks = policy
+	}
+}
+
+// Open opens the file at path, as in FileInfo.Path, for reading,
+// in the file system that t traverses (see WithFS).
+func (t *Traverser) Open(path string) (io.ReadCloser, error) {
+	return t.cfg.fileSystem().Open(path)
+}
+
+// Files traverses roots and calls handler for each file.
+//
+// It returns a *TraversalError if any error occurs, or ctx.Err() if
+// the traversal is stopped by ctx without other errors.
+func (t *Traverser) Files(ctx context.Context,
+	handler FileHandler, roots ...string) error {
+	return t.files(ctx, handler, true, roots)
+}
+
+func (t *Traverser) files(ctx context.Context, handler FileHandler,
+	collectFileErrs bool, roots []string) error {
+	if handler == nil {
+		panic(errors.New("gotfp: file handler is nil"))
+	}
+	tr := newTraversal(&t.cfg)
+	tr.CollectFileErrs = collectFileErrs
+	return t.traverse(ctx, tr, makeTraverseFilesHandler(handler, tr), roots)
+}
+
+// Batches traverses roots and calls handler for each directory,
+// along with its children grouped by category.
+//
+// It returns a *TraversalError if any error occurs, or ctx.Err() if
+// the traversal is stopped by ctx without other errors.
+func (t *Traverser) Batches(ctx context.Context,
+	handler BatchHandler, roots ...string) error {
+	return t.batches(ctx, handler, true, roots)
+}
+
+func (t *Traverser) batches(ctx context.Context, handler BatchHandler,
+	collectFileErrs bool, roots []string) error {
+	if handler == nil {
+		panic(errors.New("gotfp: batch handler is nil"))
+	}
+	tr := newTraversal(&t.cfg)
+	tr.CollectFileErrs = collectFileErrs
+	return t.traverse(ctx, tr, makeTraverseBatchesHandler(handler, tr), roots)
+}
+
+// FilesWithBatch traverses roots and calls handler for each file,
+// along with the location of the file in the batch of its parent.
+//
+// It returns a *TraversalError if any error occurs, or ctx.Err() if
+// the traversal is stopped by ctx without other errors.
+func (t *Traverser) FilesWithBatch(ctx context.Context,
+	handler FileWithBatchHandler, roots ...string) error {
+	if handler == nil {
+		panic(errors.New("gotfp: file handler is nil"))
+	}
+	tr := newTraversal(&t.cfg)
+	return t.traverse(ctx, tr,
+		makeTraverseFilesWithBatchHandler(handler, tr), roots)
+}
+
+// FilesWithLeave is like Files, but also calls leaveHandler for each
+// directory descended into, once all its descendants have been handled,
+// so that values can be aggregated bottom-up: handler returns a value
+// for each file, and leaveHandler combines the values of the children
+// of a directory into a value for its parent.
+//
+// The value returned by handler for a directory descended into is dropped.
+// A directory skipped by handler is not left, and its value is the one
+// returned by handler. Directories not included (see WithInclude) are
+// still left if descended into. The values of roots are dropped,
+// so handle them in leaveHandler with depth 0.
+// Once the traversal exits, no more directories are left.
+//
+// It returns a *TraversalError if any error occurs, or ctx.Err() if
+// the traversal is stopped by ctx without other errors.
+func (t *Traverser) FilesWithLeave(ctx context.Context,
+	handler FileValueHandler, leaveHandler LeaveHandler,
+	roots ...string) error {
+	if handler == nil {
+		panic(errors.New("gotfp: file handler is nil"))
+	}
+	if leaveHandler == nil {
+		panic(errors.New("gotfp: leave handler is nil"))
+	}
+	tr := newTraversal(&t.cfg)
+	return t.traverse(ctx, tr,
+		makeTraverseFilesWithLeaveHandler(handler, leaveHandler, tr), roots)
+}
+
+// descends reports whether the file at depth is a directory that
+// the traversal reads, unless the handler skips it.
+func (t *Traverser) descends(info FileInfo, depth int) bool {
+	return t.cfg.descends(info, depth)
+}
+
+// Ensure tr != nil && h != nil.
+func (t *Traverser) traverse(ctx context.Context,
+	tr *tTraversal, h taskHandler, roots []string) error {
+	if ctx == nil {
+		panic(errors.New("gotfp: context is nil"))
+	}
+	if len(roots) == 0 {
+		// No file to traverse. Just exit.
+		return nil
+	}
+	var err error
+	if tr.Prefetch != nil {
+		err = deliverInOrder(ctx, h, tr, roots...)
+	} else {
+		err = callDfw(ctx, h, tr, roots...)
+	}
+	tr.OpenDirs.closeAll()
+	return tr.Errs.result(err)
+}
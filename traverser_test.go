@@ -0,0 +1,131 @@
+package gotfp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testMakeTree creates files under a temporary directory and returns it.
+// Paths ending with "/" are created as directories.
+func testMakeTree(tb testing.TB, paths ...string) string {
+	tb.Helper()
+	root := tb.TempDir()
+	for _, p := range paths {
+		full := filepath.Join(root, filepath.FromSlash(p))
+		if strings.HasSuffix(p, "/") {
+			if err := os.MkdirAll(full, 0o755); err != nil {
+				tb.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			tb.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(p), 0o644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return root
+}
+
+// testCollectFiles traverses root with t and returns the slash-separated
+// paths relative to root passed to the file handler, sorted.
+func testCollectFiles(tb testing.TB, t *Traverser, root string) []string {
+	tb.Helper()
+	var mu sync.Mutex
+	var paths []string
+	err := t.Files(context.Background(), func(info FileInfo, depth int) Action {
+		rel, err := filepath.Rel(root, info.Path)
+		if err != nil {
+			tb.Error(err)
+			return ActionExit
+		}
+		mu.Lock()
+		paths = append(paths, filepath.ToSlash(rel))
+		mu.Unlock()
+		return ActionContinue
+	}, root)
+	if err != nil {
+		tb.Error(err)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestTraverser_Files(t *testing.T) {
+	root := testMakeTree(t, "a/b/c.txt", "a/d.txt", "e/", "f.txt")
+	want := []string{".", "a", "a/b", "a/b/c.txt", "a/d.txt", "e", "f.txt"}
+	for _, order := range []SortOrder{SortByCategory, SortByName, SortNone} {
+		t.Run(order.String(), func(t *testing.T) {
+			tr := NewTraverser(WithWorkerNumber(2), WithSortOrder(order))
+			got := testCollectFiles(t, tr, root)
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("got %q; want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestTraverser_Batches(t *testing.T) {
+	root := testMakeTree(t, "a/b/c.txt", "a/d.txt", "e/", "f.txt")
+	var mu sync.Mutex
+	numRegFiles := make(map[string]int)
+	err := NewTraverser().Batches(context.Background(),
+		func(batch Batch, depth int) (Action, map[string]bool) {
+			rel, err := filepath.Rel(root, batch.Parent.Path)
+			if err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			numRegFiles[filepath.ToSlash(rel)] = len(batch.RegFiles)
+			mu.Unlock()
+			return ActionContinue, nil
+		}, root)
+	if err != nil {
+		t.Error(err)
+	}
+	want := map[string]int{".": 1, "a": 1, "a/b": 1, "e": 0}
+	if len(numRegFiles) != len(want) {
+		t.Errorf("got %v; want %v", numRegFiles, want)
+	}
+	for k, v := range want {
+		if numRegFiles[k] != v {
+			t.Errorf("%q: got %d regular files; want %d", k, numRegFiles[k], v)
+		}
+	}
+}
+
+func TestWithSortOrder_Unknown(t *testing.T) {
+	defer func() {
+		if _, ok := recover().(*UnknownSortOrderError); !ok {
+			t.Error("no UnknownSortOrderError panic")
+		}
+	}()
+	NewTraverser(WithSortOrder(SortOrder(100)))
+}
+
+func TestTraverser_descends(t *testing.T) {
+	tr := NewTraverser(WithMaxDepth(2))
+	testCases := []struct {
+		info  FileInfo
+		depth int
+		want  bool
+	}{
+		{FileInfo{Cat: Directory}, 1, true},
+		{FileInfo{Cat: Directory}, 2, false},
+		{FileInfo{Cat: Directory, MountPoint: true}, 1, false},
+		{FileInfo{Cat: RegularFile}, 1, false},
+		{FileInfo{Cat: ErrorFile}, 0, false},
+	}
+	for _, tc := range testCases {
+		if got := tr.descends(tc.info, tc.depth); got != tc.want {
+			t.Errorf("%v at depth %d, mount point %t: got %t; want %t",
+				tc.info.Cat, tc.depth, tc.info.MountPoint, got, tc.want)
+		}
+	}
+}
@@ -1,13 +1,37 @@
 package gotfp
 
-import "os"
+import (
+	"io/fs"
+	"os"
+
+	"github.com/donyori/gotfp/mountinfo"
+)
 
 type FileInfo struct {
-	Path  string
-	Cat   FileCategory
-	Info  os.FileInfo
+	Path string
+	Cat  FileCategory
+	// Info of a file read from its parent directory is loaded lazily,
+	// on the first call to its methods other than Name and IsDir.
+	// Use LoadInfo to get the error of loading it.
+	Info os.FileInfo
+	// Chldn is the names of children of a directory.
+	// During traversal, a directory is read only when its own task runs,
+	// so Chldn is only set in Batch.Parent, not in the FileInfo passed to
+	// FileHandler and FileWithBatchHandler, nor in Batch.Dirs.
 	Chldn []string
 	Err   error
+	// MountPoint is true for a directory on a different device from
+	// its root, with WithSameFileSystem. It is not descended into.
+	MountPoint bool
+	// Mount is the mount on a directory that is a mount point,
+	// with WithMountInfo or WithExcludeFSTypes.
+	Mount *mountinfo.Mount
+	// HardLink is set for a file other than a directory with several
+	// hard links, with MarkHardLinks.
+	HardLink *HardLinkInfo
+
+	entries []fs.DirEntry // Directory entries of children, in the order of Chldn.
+	dirs    *tDirChain    // The directory and its ancestors, only set when following symbolic links.
 }
 
 type Batch struct {
@@ -17,6 +41,20 @@ type Batch struct {
 	Others   []FileInfo
 	Symlinks []FileInfo
 	Dirs     []FileInfo
+
+	// When reading directories chunk by chunk (see WithChunkSize),
+	// a directory is delivered in several batches, each for a chunk.
+	// ChunkIdx is the index of the chunk, and IsLastChunk is true for
+	// the last one. Otherwise, they are always 0 and true.
+	ChunkIdx    int
+	IsLastChunk bool
+}
+
+// HardLinkInfo describes a file with several hard links.
+type HardLinkInfo struct {
+	NumLinks  uint64 // Number of hard links to the file.
+	FirstPath string // The path through which the file was seen first.
+	IsRepeat  bool   // Whether the file was seen before, through FirstPath.
 }
 
 type LocationBatchInfo struct {
@@ -31,3 +69,27 @@ type BatchHandler func(batch Batch, depth int) (
 
 type FileWithBatchHandler func(info FileInfo, lctn *LocationBatchInfo,
 	depth int) Action
+
+// FileValueHandler is like FileHandler, but also returns a value of
+// the file for its parent directory. See LeaveHandler.
+type FileValueHandler func(info FileInfo, depth int) (
+	action Action, value interface{})
+
+// LeaveHandler is called for a directory once all its descendants have
+// been handled, by the worker handling the last of them.
+// childValues are the values of its children, in the order of Chldn:
+// for a sub-directory descended into, the value returned by LeaveHandler;
+// for other children, the value returned by FileValueHandler.
+// Children not handled (e.g., excluded) have no value.
+// It returns the value of dir for its parent.
+type LeaveHandler func(dir FileInfo, depth int,
+	childValues []interface{}) interface{}
+
+// LoadInfo returns fi.Info, loading it first if it is loaded lazily.
+// It returns the error if loading fails (e.g., the file has been removed).
+func (fi FileInfo) LoadInfo() (os.FileInfo, error) {
+	if lfi, ok := fi.Info.(*tLazyFileInfo); ok {
+		return lfi.load()
+	}
+	return fi.Info, nil
+}
@@ -1,52 +1,186 @@
 package gotfp
 
-import (
-	"os"
-	"sort"
-)
+import "io/fs"
 
 func GetFileInfo(path string) FileInfo {
-	info, err := os.Lstat(path)
+	info, _ := getFileInfo(path, nil, nil, nil, false)
+	if info.Cat == Directory {
+		if err := readChildren(&info, nil); err != nil {
+			info.Cat, info.Err = ErrorFile, err
+		}
+	}
+	return info
+}
+
+// entry is the directory entry of the file read from its parent,
+// or nil if unknown. If entry is not nil, the file is categorized by
+// the type of entry, without stat, and its Info is loaded lazily.
+// It does not read the children of a directory. Use readChildren instead.
+// If tr is nil, default settings are used.
+// parent is the task of the directory containing the file,
+// or nil if the file is a root or not in the traversal.
+// If the file is excluded by tr according to parent, it returns with
+// excluded set to true, and the file is not read.
+// visit is true if the file is in the traversal, and false if it is read
+// only to provide the context (e.g., the batch of the parent of a root).
+// If visit is true and symbolic links are followed, a directory that is
+// the same as one of its ancestors is reported as an ErrorFile with
+// a *SymlinkCycleError.
+// If visit is true, the error of an ErrorFile is collected into tr.Errs
+// if tr.CollectFileErrs is true.
+func getFileInfo(path string, entry fs.DirEntry, tr *tTraversal,
+	parent *tTask, visit bool) (fileInfo FileInfo, excluded bool) {
+	fsys, follow := osFileSystem, false
+	if tr != nil {
+		fsys = tr.FS
+		follow = tr.Cfg.followSymlinks
+	}
+	var info fs.FileInfo
+	var mode fs.FileMode // Type bits only.
+	var err error
+	if entry != nil {
+		info, mode = newLazyFileInfo(entry), entry.Type()
+	} else {
+		info, err = fsys.Lstat(path)
+		if info != nil {
+			mode = info.Mode().Type()
+		}
+	}
+	if tr.excludes(parent, path, mode.IsDir()) {
+		return FileInfo{Path: path}, true
+	}
+	if err == nil && info != nil && follow && mode&fs.ModeSymlink != 0 {
+		// Use the info of the target. Keep the link if it is dangling.
+		if target, e := fsys.Stat(path); e == nil && target != nil {
+			info, mode = target, target.Mode().Type()
+		}
+	}
 	var category FileCategory
-	var childrenNames []string
+	var dirs *tDirChain
 	if err != nil || info == nil {
 		category = ErrorFile
-	} else if info.Mode()&os.ModeSymlink != 0 {
+	} else if mode&fs.ModeSymlink != 0 {
 		category = Symlink
-	} else if info.IsDir() {
-		// Get the name of files under this directory.
-		childrenNames, err = readDirNames(path)
-		if err == nil {
-			category = Directory
-		} else {
-			category = ErrorFile
-		}
-	} else if info.Mode().IsRegular() {
+	} else if mode.IsDir() {
+		category = Directory
+		if follow && visit {
+			if id, ok := getFileID(fsys, path, info); ok {
+				if parent != nil {
+					dirs = parent.FileInfo.dirs
+				}
+				if ancestor, ok := dirs.find(id); ok {
+					category = ErrorFile
+					err = &SymlinkCycleError{Path: path, VisitedPath: ancestor}
+				} else {
+					dirs = &tDirChain{ID: id, Path: path, Parent: dirs}
+				}
+			}
+		}
+	} else if mode.IsRegular() {
 		category = RegularFile
 	} else {
 		category = OtherFile
 	}
-	return FileInfo{
-		Path:  path,
-		Cat:   category,
-		Info:  info,
-		Chldn: childrenNames,
-		Err:   err,
+	if err != nil && visit && tr != nil && tr.CollectFileErrs {
+		tr.Errs.add(path, err)
+	}
+	fileInfo = FileInfo{
+		Path: path,
+		Cat:  category,
+		Info: info,
+		Err:  err,
+		dirs: dirs,
+	}
+	if visit && tr != nil && tr.Links != nil &&
+		(category == RegularFile || category == OtherFile || category == Symlink) {
+		if n, ok := getLinkCount(info); ok && n > 1 {
+			if id, ok := getFileID(fsys, path, info); ok {
+				first, loaded := tr.Links.LoadOrStore(id, path)
+				if loaded && parent != nil && tr.Cfg.hardLinks == SkipHardLinks {
+					return FileInfo{Path: path}, true
+				}
+				if tr.Cfg.hardLinks == MarkHardLinks {
+					fileInfo.HardLink = &HardLinkInfo{
+						NumLinks:  n,
+						FirstPath: first,
+						IsRepeat:  loaded,
+					}
+				}
+			}
+		}
+	}
+	if category == Directory && tr != nil && tr.Mounts != nil {
+		fileInfo.Mount = tr.Mounts.Lookup(path)
+		if parent != nil && tr.excludesMount(fileInfo.Mount) {
+			return FileInfo{Path: path}, true
+		}
+	}
+	if category == Directory && parent != nil && tr != nil &&
+		tr.Cfg.sameFileSystem {
+		if rootDev, ok := tr.RootDevs.Load(parent.Root); ok {
+			if dev, ok := getDeviceID(info); ok && dev != rootDev.(uint64) {
+				fileInfo.MountPoint = true
+			}
+		}
 	}
+	return
 }
 
-func readDirNames(dirPath string) (dirNames []string, err error) {
-	dirFile, err := os.Open(dirPath)
-	if err != nil {
-		return nil, err
+// readChildren reads the directory, and sets Chldn and the entries of
+// fileInfo. If tr is nil, default settings are used.
+// Ensure fileInfo != nil && fileInfo.Cat == Directory.
+func readChildren(fileInfo *FileInfo, tr *tTraversal) error {
+	fsys, sorted := osFileSystem, true
+	if tr != nil {
+		fsys = tr.FS
+		sorted = tr.Cfg.sortOrder != SortNone
 	}
-	defer dirFile.Close() // Ignore error.
-	dirNames, err = dirFile.Readdirnames(0)
+	entries, err := fsys.ReadDir(fileInfo.Path, sorted)
 	if err != nil {
-		dirNames = nil
+		return err
 	}
-	if len(dirNames) > 0 {
-		sort.Strings(dirNames)
+	setChildren(fileInfo, entries)
+	return nil
+}
+
+// setChildren sets the children of fileInfo to entries.
+func setChildren(fileInfo *FileInfo, entries []fs.DirEntry) {
+	var childrenNames []string
+	if len(entries) > 0 {
+		childrenNames = make([]string, len(entries))
+		for i := range entries {
+			childrenNames[i] = entries[i].Name()
+		}
 	}
-	return
+	fileInfo.Chldn, fileInfo.entries = childrenNames, entries
+}
+
+// readTaskChildren reads the children of the directory of task,
+// and then loads the gitignore rules for them if needed.
+// If tr.Cfg.chunkSize is positive, only the next chunk is read.
+// Ensure task.FileInfo.Cat == Directory && tr != nil.
+func readTaskChildren(task *tTask, tr *tTraversal, errBuf *[]error) error {
+	if tr.Cfg.chunkSize > 0 {
+		if err := tr.readChunk(task, errBuf); err != nil {
+			return err
+		}
+		if task.Chunk == 0 && tr.Cfg.gitignore {
+			task.Ignore = tr.dirIgnore(task, false, errBuf)
+		}
+		return nil
+	}
+	if entries, ok := tr.Prefetch.take(task.FileInfo.Path); ok {
+		setChildren(&task.FileInfo, entries)
+	} else if err := readChildren(&task.FileInfo, tr); err != nil {
+		return err
+	}
+	if tr.Cfg.gitignore {
+		task.Ignore = tr.dirIgnore(task, true, errBuf)
+	}
+	return nil
+}
+
+func isDir(fileInfo FileInfo) bool {
+	return fileInfo.Cat == Directory ||
+		fileInfo.Info != nil && fileInfo.Info.IsDir()
 }
@@ -0,0 +1,115 @@
+package gotfp
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestFileInfo_LoadInfo(t *testing.T) {
+	root := testMakeTree(t, "a.txt", "b.txt")
+	var called bool
+	err := NewTraverser(WithWorkerNumber(1)).Files(context.Background(),
+		func(info FileInfo, depth int) Action {
+			if depth == 0 {
+				if _, err := info.LoadInfo(); err != nil {
+					t.Error(err)
+				}
+				return ActionContinue
+			}
+			if info.Cat != RegularFile {
+				t.Errorf("%q: got %v; want %v", info.Path, info.Cat, RegularFile)
+			}
+			if filepath.Base(info.Path) != "a.txt" {
+				fi, err := info.LoadInfo()
+				if err != nil {
+					t.Error(err)
+				} else if fi.Size() != int64(len("b.txt")) {
+					t.Errorf("got size %d; want %d", fi.Size(), len("b.txt"))
+				}
+				return ActionContinue
+			}
+			called = true
+			// Info has not been loaded, so it fails after the file is removed.
+			if err := os.Remove(info.Path); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := info.LoadInfo(); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("got error %v; want %v", err, fs.ErrNotExist)
+			}
+			if info.Info.Name() != "a.txt" || info.Info.Size() != 0 ||
+				!info.Info.Mode().IsRegular() {
+				t.Error("got wrong lazy info")
+			}
+			return ActionContinue
+		}, root)
+	if err != nil {
+		t.Error(err)
+	}
+	if !called {
+		t.Error("a.txt not found")
+	}
+}
+
+// Compare the categorization with directory entries to that with Lstat.
+func BenchmarkGetFileInfo(b *testing.B) {
+	if n, m := testWalkDirEntry(testRoot, nil), testWalkLstat(testRoot); n != m {
+		b.Fatalf("walked %d files with directory entries; want %d", n, m)
+	}
+	b.Run("DirEntry", func(b *testing.B) {
+		var n int
+		for b.Loop() {
+			n = testWalkDirEntry(testRoot, nil)
+		}
+		b.ReportMetric(float64(n), "files/op")
+	})
+	b.Run("Lstat", func(b *testing.B) {
+		var n int
+		for b.Loop() {
+			n = testWalkLstat(testRoot)
+		}
+		b.ReportMetric(float64(n), "files/op")
+	})
+}
+
+// testWalkDirEntry walks as the traversal does: categorize each file
+// by its directory entry and then read the children of directories.
+func testWalkDirEntry(path string, entry fs.DirEntry) int {
+	fileInfo, _ := getFileInfo(path, entry, nil, nil, false)
+	if fileInfo.Cat != Directory || readChildren(&fileInfo, nil) != nil {
+		return 1
+	}
+	n := 1
+	for _, e := range fileInfo.entries {
+		n += testWalkDirEntry(filepath.Join(path, e.Name()), e)
+	}
+	return n
+}
+
+// testWalkLstat walks as GetFileInfo did before using directory entries:
+// stat each file and then read the names of children of directories.
+func testWalkLstat(path string) int {
+	info, err := os.Lstat(path)
+	if err != nil || !info.IsDir() {
+		return 1
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 1
+	}
+	names, err := f.Readdirnames(0)
+	f.Close()
+	if err != nil {
+		return 1
+	}
+	sort.Strings(names)
+	n := 1
+	for _, name := range names {
+		n += testWalkLstat(filepath.Join(path, name))
+	}
+	return n
+}
@@ -1,47 +1,92 @@
 package gotfp
 
 import (
-	"path/filepath"
+	"context"
+	"sync"
+	"sync/atomic"
 
-	"github.com/donyori/goctpf"
 	"github.com/donyori/goctpf/idtpf/dfw"
 	"github.com/donyori/goctpf/prefab"
 )
 
-// Ensure handler != nil && len(roots) > 0.
-func callDfw(handler taskHandler,
-	workerSettings goctpf.WorkerSettings,
-	workerErrChan chan<- error,
-	roots ...string) {
+// Ensure ctx != nil && handler != nil && tr != nil && len(roots) > 0.
+// It returns ctx.Err() if the traversal is stopped by ctx, otherwise nil.
+func callDfw(ctx context.Context,
+	handler taskHandler,
+	tr *tTraversal,
+	roots ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	its := make([]interface{}, 0, len(roots)) // initial tasks
 	for i := range roots {
-		// Try to get absolute path.
-		root, err := filepath.Abs(roots[i])
-		if err != nil {
-			root = filepath.Clean(roots[i])
-		}
+		root := tr.FS.Root(roots[i])
 		its = append(its, &tTask{
 			FileInfo: FileInfo{Path: root},
 			Depth:    0,
+			Root:     root,
 		})
 	}
+	levelSync := tr.Cfg.order == LevelSync
+	var isCancelled, hasExited int32
+	var nextLevelMu sync.Mutex
+	var nextLevel []interface{} // Tasks for the next level, in LevelSync.
 	h := func(workerNo int, task interface{}, errBuf *[]error) (
 		newTasks []interface{}, doesExit bool) {
+		// Don't schedule any new task after ctx is done.
+		if ctx.Err() != nil {
+			atomic.StoreInt32(&isCancelled, 1)
+			return nil, true
+		}
 		t := task.(*tTask)
+		numErr := len(*errBuf)
 		nextTasks, doesExit := handler(t, errBuf)
-		if doesExit || len(nextTasks) == 0 {
-			return nil, doesExit
+		for _, err := range (*errBuf)[numErr:] {
+			tr.Errs.add(t.FileInfo.Path, err)
+		}
+		if doesExit {
+			atomic.StoreInt32(&hasExited, 1)
+			return nil, true
+		}
+		if len(nextTasks) == 0 {
+			return
+		}
+		if ctx.Err() != nil {
+			atomic.StoreInt32(&isCancelled, 1)
+			return nil, true
 		}
 		newTasks = make([]interface{}, 0, len(nextTasks))
 		newDepth := t.Depth + 1
 		for _, newTask := range nextTasks {
-			if newTask.Depth <= 0 {
+			if newTask.Chunk > 0 || newTask.Shard != nil {
+				newTask.Depth = t.Depth
+			} else if newTask.Depth <= 0 {
 				newTask.Depth = newDepth
 			}
+			if levelSync && newTask.Depth > t.Depth {
+				nextLevelMu.Lock()
+				nextLevel = append(nextLevel, newTask)
+				nextLevelMu.Unlock()
+				continue
+			}
 			newTasks = append(newTasks, newTask)
 		}
 		return newTasks, false
 	}
-	dfw.DoEx(prefab.LdgbTaskManagerMaker, h, nil, nil,
-		workerSettings, workerErrChan, its...)
+	tmm := prefab.LdgbTaskManagerMaker
+	if tr.Cfg.order == BreadthFirst || levelSync {
+		tmm = prefab.FifoTaskManagerMaker
+	}
+	// In LevelSync, run once for each level, so that a level starts
+	// only after the previous one is done.
+	for len(its) > 0 && atomic.LoadInt32(&hasExited) == 0 &&
+		atomic.LoadInt32(&isCancelled) == 0 {
+		dfw.DoEx(tmm, h, nil, nil,
+			tr.Cfg.workerSettings, tr.Cfg.workerErrChan, its...)
+		its, nextLevel = nextLevel, nil
+	}
+	if atomic.LoadInt32(&isCancelled) != 0 {
+		return ctx.Err()
+	}
+	return nil
 }